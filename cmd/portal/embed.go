@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+var embedThemes = []string{"light", "dark"}
+
+// handleEmbedPage renders a compact view of a manifest's funding plans and
+// channels that can be embedded in third party websites via an iframe
+// (or the static/embed.js script).
+//
+// /embed/@github.com/user?theme=dark&project=project-guid
+func handleEmbedPage(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		mGuid = strings.TrimSuffix(c.Param("*"), "/")
+		pGuid = c.QueryParam("project")
+		theme = c.QueryParam("theme")
+	)
+
+	if !slices.Contains(embedThemes, theme) {
+		theme = embedThemes[0]
+	}
+
+	// Allow the page to be framed only by the configured origins.
+	c.Response().Header().Set("Content-Security-Policy", "frame-ancestors "+strings.Join(app.consts.EmbedFrameAncestors, " "))
+
+	out := struct {
+		Page
+		Manifest models.ManifestData
+		Project  models.Project
+		Plans    v1.Plans
+		Theme    string
+	}{}
+	out.Theme = theme
+
+	m, err := app.core.GetManifest(0, mGuid, core.ManifestStatusActive)
+	if err != nil {
+		out.Title = "Error"
+		if err == core.ErrNotFound {
+			out.ErrMessage = "Manifest not found."
+			return c.Render(http.StatusNotFound, "embed", out)
+		}
+
+		out.ErrMessage = "Error fetching manifest."
+		return c.Render(http.StatusInternalServerError, "embed", out)
+	}
+
+	// Optional project filter.
+	if pGuid != "" {
+		n := slices.IndexFunc(m.Projects, func(o models.Project) bool {
+			return o.GUID == mGuid+"/"+pGuid
+		})
+		if n < 0 {
+			out.Title = "Error"
+			out.ErrMessage = "Project not found."
+			return c.Render(http.StatusNotFound, "embed", out)
+		}
+		out.Project = m.Projects[n]
+	}
+
+	// Only show plans that are currently active.
+	for _, p := range m.Funding.Plans {
+		if p.Status == "active" {
+			out.Plans = append(out.Plans, p)
+		}
+	}
+
+	out.Manifest = m
+	out.Title = "Fund " + m.Entity.Name
+	out.Heading = m.Entity.Name
+	if out.Project.GUID != "" {
+		out.Title = "Fund " + out.Project.Name
+		out.Heading = out.Project.Name
+	}
+
+	return c.Render(http.StatusOK, "embed", out)
+}
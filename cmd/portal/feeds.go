@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	feedAtom = "atom"
+	feedRSS  = "rss"
+	feedJSON = "json"
+)
+
+// feedTypes maps feed names in URIs to the project date field they're ordered by.
+var feedTypes = map[string]string{
+	"new":     "created_at",
+	"updated": "updated_at",
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomAuthor     `xml:"author"`
+	Summary    string         `xml:"summary"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	NSDC    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Author      string   `xml:"dc:creator"`
+	Description string   `xml:"description"`
+	Categories  []string `xml:"category"`
+}
+
+// jsonFeed is a JSON Feed (https://jsonfeed.org/version/1.1).
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentText   string           `json:"content_text"`
+	DatePublished time.Time        `json:"date_published"`
+	DateModified  time.Time        `json:"date_modified"`
+	Authors       []jsonFeedAuthor `json:"authors"`
+	Tags          []string         `json:"tags"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// handleFeed renders Atom, RSS, or JSON feeds of recently listed or updated projects.
+//
+// /feeds/new.atom, /feeds/updated.rss, /feeds/tag/{tag}.json
+func handleFeed(c echo.Context) error {
+	var (
+		app = c.Get("app").(*App)
+
+		feed, format = splitFeedName(c.Param("feed"))
+		tag, tagFmt  = splitFeedName(c.Param("tag"))
+	)
+
+	// Tag feeds are always feeds of new projects.
+	if tag != "" {
+		feed, format = "new", tagFmt
+	}
+
+	orderBy, ok := feedTypes[feed]
+	if !ok || (format != feedAtom && format != feedRSS && format != feedJSON) {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown feed")
+	}
+
+	res, err := app.core.GetFeedProjects(orderBy, tag, app.consts.FeedNumItems)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error fetching feed.")
+	}
+
+	var (
+		title   = "Recently listed projects"
+		selfURL = app.consts.RootURL + c.Request().URL.Path
+	)
+	if feed == "updated" {
+		title = "Recently updated projects"
+	}
+	if tag != "" {
+		title = fmt.Sprintf("Recently listed projects tagged #%s", tag)
+	}
+	title += " - FLOSS/Fund"
+
+	switch format {
+	case feedAtom:
+		return c.Blob(http.StatusOK, "application/atom+xml; charset=UTF-8", makeAtomFeed(title, selfURL, app.consts.RootURL, res, orderBy == "updated_at"))
+	case feedRSS:
+		return c.Blob(http.StatusOK, "application/rss+xml; charset=UTF-8", makeRSSFeed(title, app.consts.RootURL, res, orderBy == "updated_at"))
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "application/feed+json; charset=UTF-8")
+	return c.JSON(http.StatusOK, makeJSONFeed(title, selfURL, app.consts.RootURL, res))
+}
+
+// makeAtomFeed generates an Atom feed XML document from the given projects.
+func makeAtomFeed(title, selfURL, rootURL string, projects models.Projects, byUpdated bool) []byte {
+	out := atomFeed{
+		Title:   title,
+		ID:      selfURL,
+		Updated: feedUpdated(projects, byUpdated).Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: selfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: rootURL, Rel: "alternate", Type: "text/html"},
+		},
+		Entries: make([]atomEntry, 0, len(projects)),
+	}
+
+	for _, p := range projects {
+		u := projectURL(rootURL, p)
+		out.Entries = append(out.Entries, atomEntry{
+			Title:     p.Name,
+			ID:        u,
+			Link:      atomLink{Href: u, Rel: "alternate", Type: "text/html"},
+			Published: p.CreatedAt.Format(time.RFC3339),
+			Updated:   p.UpdatedAt.Format(time.RFC3339),
+			Author: atomAuthor{
+				Name: p.Entity.Name,
+				URI:  fmt.Sprintf("%s/view/%s", rootURL, p.Entity.ManifestGUID),
+			},
+			Summary:    p.Description,
+			Categories: atomCategories(p.Tags),
+		})
+	}
+
+	b, _ := xml.Marshal(out)
+	return append([]byte(xml.Header), b...)
+}
+
+// makeRSSFeed generates an RSS 2.0 feed XML document from the given projects.
+func makeRSSFeed(title, rootURL string, projects models.Projects, byUpdated bool) []byte {
+	out := rssFeed{
+		Version: "2.0",
+		NSDC:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         title,
+			Link:          rootURL,
+			Description:   "Free and open source projects seeking funding",
+			LastBuildDate: feedUpdated(projects, byUpdated).Format(time.RFC1123Z),
+			Items:         make([]rssItem, 0, len(projects)),
+		},
+	}
+
+	for _, p := range projects {
+		date := p.CreatedAt
+		if byUpdated {
+			date = p.UpdatedAt
+		}
+
+		u := projectURL(rootURL, p)
+		out.Channel.Items = append(out.Channel.Items, rssItem{
+			Title:       p.Name,
+			Link:        u,
+			GUID:        u,
+			PubDate:     date.Format(time.RFC1123Z),
+			Author:      p.Entity.Name,
+			Description: p.Description,
+			Categories:  p.Tags,
+		})
+	}
+
+	b, _ := xml.Marshal(out)
+	return append([]byte(xml.Header), b...)
+}
+
+// makeJSONFeed generates a JSON Feed from the given projects.
+func makeJSONFeed(title, selfURL, rootURL string, projects models.Projects) jsonFeed {
+	out := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       title,
+		HomePageURL: rootURL,
+		FeedURL:     selfURL,
+		Items:       make([]jsonFeedItem, 0, len(projects)),
+	}
+
+	for _, p := range projects {
+		u := projectURL(rootURL, p)
+		out.Items = append(out.Items, jsonFeedItem{
+			ID:            u,
+			URL:           u,
+			Title:         p.Name,
+			ContentText:   p.Description,
+			DatePublished: p.CreatedAt,
+			DateModified:  p.UpdatedAt,
+			Authors: []jsonFeedAuthor{{
+				Name: p.Entity.Name,
+				URL:  fmt.Sprintf("%s/view/%s", rootURL, p.Entity.ManifestGUID),
+			}},
+			Tags: p.Tags,
+		})
+	}
+
+	return out
+}
+
+// splitFeedName splits a feed file name (eg: new.atom) into the name and the format.
+func splitFeedName(s string) (string, string) {
+	ext := path.Ext(s)
+	return strings.TrimSuffix(s, ext), strings.TrimPrefix(ext, ".")
+}
+
+// feedUpdated returns the most recent date of the given projects.
+func feedUpdated(projects models.Projects, byUpdated bool) time.Time {
+	var t time.Time
+	for _, p := range projects {
+		d := p.CreatedAt
+		if byUpdated {
+			d = p.UpdatedAt
+		}
+		if d.After(t) {
+			t = d
+		}
+	}
+
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	return t
+}
+
+func atomCategories(tags []string) []atomCategory {
+	out := make([]atomCategory, 0, len(tags))
+	for _, t := range tags {
+		out = append(out, atomCategory{Term: t})
+	}
+	return out
+}
+
+func projectURL(rootURL string, p models.Project) string {
+	return fmt.Sprintf("%s/view/project/%s", rootURL, p.GUID)
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"crypto/md5"
 	"crypto/subtle"
+	"fmt"
 	"net/http"
 	"path"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/altcha-org/altcha-lib-go"
+	"github.com/floss-fund/portal/internal/core"
 	"github.com/knadh/koanf/v2"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -17,23 +23,46 @@ const (
 )
 
 func initHandlers(ko *koanf.Koanf, srv *echo.Echo) {
+	// ETag and Cache-Control headers for rendered pages.
+	pc := pageCache(ko.Duration("cache.max_age"))
+
 	g := srv.Group("")
-	g.GET("/", handleIndexPage)
+	g.GET("/", handleIndexPage, pc)
 	g.GET("/submit", handleSubmitPage)
 	g.POST("/submit", handleSubmitPage)
 	g.GET("/validate", handleValidatePage)
 	g.POST("/validate", handleValidatePage)
-	g.GET("/search", handleSearchPage)
-	g.GET("/browse/projects", handleBrowseProjectsPage)
-	g.GET("/browse/entities", handleBrowseEntitiesPage)
+	g.GET("/search", handleSearchPage, pc)
+	g.GET("/browse/projects", handleBrowseProjectsPage, pc)
+	g.GET("/browse/entities", handleBrowseEntitiesPage, pc)
 	g.GET("/browse/export", handleExportPage)
-	g.GET("/view/funding", handleManifestPage)
-	g.GET("/view/projects", handleManifestPage)
-	g.GET("/view/project", handleManifestPage)
-	g.GET("/view/*", handleManifestPage)
+	g.GET("/stats", handleStatsPage, pc)
+	g.GET("/tags", handleTagsPage, pc)
+	g.GET("/tags/:tag", handleTagPage, pc)
+	g.GET("/licenses", handleLicensesPage, pc)
+	g.GET("/licenses/:id", handleLicensePage, pc)
+	g.GET("/view/funding", handleManifestPage, pc)
+	g.GET("/view/projects", handleManifestPage, pc)
+	g.GET("/view/project", handleManifestPage, pc)
+	g.GET("/view/*", handleManifestPage, pc)
+	g.GET("/embed/*", handleEmbedPage, pc)
+	g.GET("/og/*", handleOGImage)
+	g.GET("/feeds/tag/:tag", handleFeed)
+	g.GET("/feeds/:feed", handleFeed)
+	g.GET("/sitemap.xml", handleSitemapIndex)
+	g.GET("/sitemap/:page", handleSitemap)
+	g.GET("/sitemap/projects/:page", handleSitemapProjects)
+	g.GET("/robots.txt", handleRobotsTxt)
+	g.GET("/health/live", handleHealthLive)
+	g.GET("/health/ready", handleHealthReady)
 
 	g.POST("/api/validate", handleValidateManifest)
 	g.GET("/api/tags", handleGetTags)
+	g.GET("/api/search", handleSearch)
+	g.GET("/api/autocomplete", handleAutocomplete)
+	g.GET("/api/projects", handleListProjects)
+	g.GET("/api/entities", handleListEntities)
+	g.GET("/api/stats", handleGetStats)
 	g.GET("/api/captcha", handleGenerateCaptcha)
 
 	g.POST("/report/:mguid", handleReport)
@@ -47,6 +76,18 @@ func initHandlers(ko *koanf.Koanf, srv *echo.Echo) {
 	a.GET("/api/manifests/:id", handleGetManifest)
 	a.DELETE("/api/manifests/:id", handleDeleteManifest)
 	a.PUT("/api/manifests/:id/status", handleUpdateManifestStatus)
+	a.PUT("/api/manifests/:id/language", handleUpdateManifestLanguage)
+	a.GET("/api/maintenance/views", handleGetViewsRefreshed)
+	a.GET("/api/maintenance/cache", handleGetCacheStats)
+	if ko.String("metrics.address") == "" {
+		a.GET("/metrics", handleMetrics)
+	}
+	a.GET("/api/tags/aliases", handleGetTagAliases)
+	a.PUT("/api/tags/aliases/:alias", handleUpsertTagAlias)
+	a.DELETE("/api/tags/aliases/:alias", handleDeleteTagAlias)
+	a.GET("/api/tags/taxonomy", handleGetTagTaxonomy)
+	a.PUT("/api/tags/taxonomy/:tag", handleUpsertTagTaxonomy)
+	a.DELETE("/api/tags/taxonomy/:tag", handleDeleteTagTaxonomy)
 	a.GET("/admin/manifests", handleAdminManifestsListing)
 	a.GET("/admin/view/*", handleAdminManifestsPage)
 
@@ -63,6 +104,23 @@ func initHandlers(ko *koanf.Koanf, srv *echo.Echo) {
 
 }
 
+func handleGetViewsRefreshed(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	out, err := app.core.GetViewsRefreshed()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error fetching view refresh times.")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+func handleGetCacheStats(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	return c.JSON(http.StatusOK, okResp{app.core.GetCacheStats()})
+}
+
 func handleGetManifest(c echo.Context) error {
 	var (
 		app   = c.Get("app").(*App)
@@ -110,6 +168,29 @@ func handleUpdateManifestStatus(c echo.Context) error {
 	return c.JSON(http.StatusOK, okResp{true})
 }
 
+// handleUpdateManifestLanguage declares the language that a manifest is searched in.
+// An empty language resets it to the detected one.
+func handleUpdateManifestLanguage(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		id, _ = strconv.Atoi(c.Param("id"))
+		lang  = strings.TrimSpace(c.FormValue("language"))
+	)
+
+	out, err := app.core.UpdateManifestLanguage(id, lang)
+	if err != nil {
+		switch err {
+		case core.ErrInvalidLanguage:
+			return echo.NewHTTPError(http.StatusBadRequest, "Unknown language.")
+		case core.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, "Manifest not found.")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
 func handleGenerateCaptcha(c echo.Context) error {
 	var (
 		app = c.Get("app").(*App)
@@ -140,3 +221,84 @@ func basicAuth(username, password string, c echo.Context) (bool, error) {
 
 	return false, nil
 }
+
+// pageCache returns a middleware that sets an ETag (a hash of the page) and Cache-Control
+// headers on rendered HTML pages, and responds with 304 Not Modified if the client's
+// copy of the page, sent in If-None-Match, is the same.
+func pageCache(maxAge time.Duration) echo.MiddlewareFunc {
+	cc := "no-cache"
+	if maxAge > 0 {
+		cc = fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Method != http.MethodGet {
+				return next(c)
+			}
+
+			// Buffer the response to hash it before it's sent.
+			var (
+				res = c.Response()
+				w   = &bufWriter{ResponseWriter: res.Writer}
+			)
+			res.Writer = w
+			err := next(c)
+			res.Writer = w.ResponseWriter
+
+			// Nothing was written. eg: the error is yet to be handled.
+			if w.code == 0 {
+				return err
+			}
+
+			if w.code == http.StatusOK && strings.HasPrefix(res.Header().Get(echo.HeaderContentType), echo.MIMETextHTML) {
+				etag := fmt.Sprintf(`"%x"`, md5.Sum(w.buf.Bytes()))
+				res.Header().Set("ETag", etag)
+				res.Header().Set("Cache-Control", cc)
+
+				if etagMatches(c.Request().Header.Get("If-None-Match"), etag) {
+					res.Status = http.StatusNotModified
+					w.ResponseWriter.WriteHeader(http.StatusNotModified)
+					return err
+				}
+			}
+
+			w.ResponseWriter.WriteHeader(w.code)
+			if _, wErr := w.ResponseWriter.Write(w.buf.Bytes()); wErr != nil && err == nil {
+				err = wErr
+			}
+
+			return err
+		}
+	}
+}
+
+// etagMatches checks whether an ETag is in an If-None-Match header's list of ETags.
+func etagMatches(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bufWriter is an http.ResponseWriter that holds the response status and body.
+type bufWriter struct {
+	http.ResponseWriter
+	buf  bytes.Buffer
+	code int
+}
+
+func (w *bufWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+func (w *bufWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.buf.Write(b)
+}
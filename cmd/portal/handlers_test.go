@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageCache(t *testing.T) {
+	srv := echo.New()
+	srv.GET("/", func(c echo.Context) error {
+		return c.HTML(http.StatusOK, "<p>page</p>")
+	}, pageCache(time.Minute))
+
+	// The page is sent with its ETag.
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "<p>page</p>", rec.Body.String())
+	assert.Equal(t, "public, max-age=60", rec.Header().Get("Cache-Control"))
+
+	etag := rec.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// The same page isn't sent again.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"abc", W/`+etag)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	req.Header.Set("If-None-Match", `"abc"`)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "<p>page</p>", rec.Body.String())
+}
+
+func TestHTTPMetrics(t *testing.T) {
+	srv := echo.New()
+	srv.Use(httpMetrics)
+	srv.GET("/tags/:tag", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusNotFound, "Tag not found")
+	})
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tags/go", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Requests are counted by the route and the status that was sent.
+	var b bytes.Buffer
+	metrics.WritePrometheus(&b, false)
+	assert.Contains(t, b.String(), `portal_http_requests_total{route="/tags/:tag",method="GET",status="404"} 1`)
+}
+
+func TestHealthLive(t *testing.T) {
+	app := &App{consts: Consts{Mode: "crawl"}}
+	app.crawl = crawl.New(&crawl.Opt{}, nil, nil, nil, log.New(io.Discard, "", 0))
+
+	srv := echo.New()
+	srv.GET("/health/live", func(c echo.Context) error {
+		c.Set("app", app)
+		return handleHealthLive(c)
+	})
+
+	// The crawler hasn't started.
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"status": "fail", "checks": {"crawler": {"status": "fail", "message": "crawler is not running"}}}`, rec.Body.String())
+
+	// The site has nothing to check.
+	app.consts.Mode = "site"
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status": "ok", "checks": {}}`, rec.Body.String())
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	healthOK   = "ok"
+	healthFail = "fail"
+
+	// Time to wait for the DB to respond to a health check.
+	healthDBTimeout = 3 * time.Second
+
+	// The crawler is considered stuck if it doesn't make progress for this long.
+	crawlerStallTimeout = 5 * time.Minute
+)
+
+type healthCheck struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+type healthResp struct {
+	Status string                 `json:"status"`
+	Checks map[string]healthCheck `json:"checks"`
+}
+
+// handleHealthLive responds whether the process is alive and, in the crawl mode,
+// whether the crawler is making progress. It's meant for liveness probes.
+func handleHealthLive(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	checks := map[string]healthCheck{}
+	if app.consts.Mode == "crawl" {
+		checks["crawler"] = checkCrawler(app)
+	}
+
+	return healthResponse(c, checks)
+}
+
+// handleHealthReady responds whether the app is ready to serve: the DB is reachable and upgraded,
+// the templates are loaded (site mode), and the crawler is making progress (crawl mode).
+// It's meant for readiness probes.
+func handleHealthReady(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), healthDBTimeout)
+	defer cancel()
+
+	checks := map[string]healthCheck{
+		"db":         checkDB(ctx, app),
+		"migrations": checkMigrations(ctx, app),
+	}
+
+	switch app.consts.Mode {
+	case "site":
+		checks["templates"] = checkTemplates(app)
+	case "crawl":
+		checks["crawler"] = checkCrawler(app)
+	}
+
+	return healthResponse(c, checks)
+}
+
+// healthResponse responds with the checks and 503 if any of them failed.
+func healthResponse(c echo.Context, checks map[string]healthCheck) error {
+	out := healthResp{Status: healthOK, Checks: checks}
+	for _, ch := range checks {
+		if ch.Status != healthOK {
+			out.Status = healthFail
+		}
+	}
+
+	code := http.StatusOK
+	if out.Status != healthOK {
+		code = http.StatusServiceUnavailable
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(code, out)
+}
+
+// checkDB pings the DB. The health endpoints are public, so the error, which may have
+// internal details such as the DB host, is logged and not returned.
+func checkDB(ctx context.Context, app *App) healthCheck {
+	if err := app.db.PingContext(ctx); err != nil {
+		app.lo.Printf("health check: error pinging DB: %v", err)
+		return healthCheck{Status: healthFail, Message: "database is unreachable"}
+	}
+
+	return healthCheck{Status: healthOK}
+}
+
+func checkMigrations(ctx context.Context, app *App) healthCheck {
+	_, toRun, err := getPendingMigrations(ctx, app.db)
+	if err != nil {
+		app.lo.Printf("health check: error checking migrations: %v", err)
+		return healthCheck{Status: healthFail, Message: "error checking migrations"}
+	}
+
+	if len(toRun) > 0 {
+		vers := make([]string, 0, len(toRun))
+		for _, m := range toRun {
+			vers = append(vers, m.version)
+		}
+		return healthCheck{Status: healthFail, Message: fmt.Sprintf("pending upgrades: %v", vers)}
+	}
+
+	return healthCheck{Status: healthOK}
+}
+
+func checkTemplates(app *App) healthCheck {
+	if app.siteTpl == nil || app.siteTpl.Lookup("index") == nil {
+		return healthCheck{Status: healthFail, Message: "templates not loaded"}
+	}
+
+	return healthCheck{Status: healthOK}
+}
+
+func checkCrawler(app *App) healthCheck {
+	running, last := app.crawl.Status()
+	if !running {
+		return healthCheck{Status: healthFail, Message: "crawler is not running"}
+	}
+
+	if since := time.Since(last); since > crawlerStallTimeout {
+		return healthCheck{Status: healthFail, Message: fmt.Sprintf("no progress for %s", since.Round(time.Second))}
+	}
+
+	return healthCheck{Status: healthOK}
+}
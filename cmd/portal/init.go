@@ -14,7 +14,10 @@ import (
 	mrand "math/rand"
 	"os"
 	"path"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 	"unicode"
@@ -22,9 +25,12 @@ import (
 	"github.com/Masterminds/sprig"
 	"github.com/floss-fund/go-funding-json/common"
 	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/cache"
 	"github.com/floss-fund/portal/internal/core"
 	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/floss-fund/portal/internal/maintenance"
 	"github.com/floss-fund/portal/internal/models"
+	"github.com/floss-fund/portal/internal/ogimg"
 	"github.com/jmoiron/sqlx"
 	"github.com/knadh/goyesql/v2"
 	goyesqlx "github.com/knadh/goyesql/v2/sqlx"
@@ -35,6 +41,7 @@ import (
 	"github.com/knadh/paginator/v2"
 	"github.com/knadh/stuffbin"
 	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
 	flag "github.com/spf13/pflag"
 )
 
@@ -52,13 +59,14 @@ func initConfig() {
 		os.Exit(0)
 	}
 
-	f.String("mode", "site", "site = runs the public portal | crawl = runs the background crawler | dump = dump raw manifest data to stdout")
+	f.String("mode", "site", "site = runs the public portal | crawl = runs the background crawler | dump = dump raw manifest data to stdout | maintenance = refresh aggregate views")
 	f.Bool("new-config", false, "generate a new sample config.toml file.")
 	f.StringSlice("config", []string{"config.toml"},
 		"path to one or more config files (will be merged in order)")
 	f.Bool("install", false, "run first time DB installation")
 	f.Bool("upgrade", false, "upgrade database to the current version")
 	f.Bool("yes", false, "assume 'yes' to prompts during --install/upgrade")
+	f.String("import-rates", "", "import currency exchange rates from a JSON or CSV file, replacing existing rates")
 	f.Bool("version", false, "current version of the build")
 
 	if err := f.Parse(os.Args[1:]); err != nil {
@@ -107,8 +115,29 @@ func initConstants(ko *koanf.Koanf) Consts {
 		EnableCaptcha:           ko.Bool("site.enable_captcha"),
 		HomeNumTags:             ko.MustInt("site.home_num_tags"),
 		HomeNumProjects:         ko.MustInt("site.home_num_projects"),
+		FeedNumItems:            ko.Int("site.feed_num_items"),
 		DefaultSubmissionstatus: ko.MustString("site.default_submission_status"),
 		DumpFileName:            ko.MustString("site.dump_filename"),
+		EmbedFrameAncestors:     ko.Strings("site.embed_frame_ancestors"),
+		DisplayCurrencies:       ko.Strings("site.display_currencies"),
+		AutocompleteTimeout:     ko.Duration("site.autocomplete_timeout"),
+		Mode:                    ko.String("mode"),
+	}
+
+	if c.FeedNumItems < 1 {
+		c.FeedNumItems = 50
+	}
+
+	if len(c.EmbedFrameAncestors) == 0 {
+		c.EmbedFrameAncestors = []string{"*"}
+	}
+
+	if len(c.DisplayCurrencies) == 0 {
+		c.DisplayCurrencies = []string{"USD", "EUR"}
+	}
+
+	if c.AutocompleteTimeout <= 0 {
+		c.AutocompleteTimeout = 250 * time.Millisecond
 	}
 
 	if c.EnableCaptcha {
@@ -175,8 +204,9 @@ func initHTTPServer(app *App, ko *koanf.Koanf) *echo.Echo {
 
 	// Generate a random string for cache busting in templates.
 	b := md5.Sum([]byte(time.Now().String()))
+	app.siteTpl = initSiteTemplates(ko.MustString("app.template_dir"), ko.MustString("crawl.wellknown_uri"))
 	srv.Renderer = &tplRenderer{
-		tpl:      initSiteTemplates(ko.MustString("app.template_dir"), ko.MustString("crawl.wellknown_uri")),
+		tpl:      app.siteTpl,
 		RootURL:  ko.MustString("app.root_url"),
 		AssetVer: fmt.Sprintf("%x", b)[0:10],
 	}
@@ -188,13 +218,14 @@ func initHTTPServer(app *App, ko *koanf.Koanf) *echo.Echo {
 			return next(c)
 		}
 	})
+	srv.Use(httpMetrics)
 
 	initHandlers(ko, srv)
 
 	return srv
 }
 
-func initCore(fs stuffbin.FileSystem, db *sqlx.DB) *core.Core {
+func initCore(fs stuffbin.FileSystem, db *sqlx.DB, ko *koanf.Koanf) *core.Core {
 	// Load SQL queries.
 	qB, err := fs.Read("/queries.sql")
 	if err != nil {
@@ -212,11 +243,60 @@ func initCore(fs stuffbin.FileSystem, db *sqlx.DB) *core.Core {
 		lo.Fatalf("no SQL queries loaded: %v", err)
 	}
 
-	opt := core.Opt{}
+	opt := core.Opt{
+		SearchLanguages: initSearchLanguages(db, ko),
+		Cache:           initCache(ko),
+	}
 
 	return core.New(&q, db.Unsafe(), opt, lo)
 }
 
+// initCache initializes the cache of reads behind public pages. It's disabled if there's no TTL.
+func initCache(ko *koanf.Koanf) *cache.Cache {
+	o := cache.Opt{
+		Size: ko.Int("cache.size"),
+		TTL:  ko.Duration("cache.ttl"),
+	}
+	if o.TTL <= 0 {
+		return nil
+	}
+
+	if o.Size < 1 {
+		o.Size = 10000
+	}
+
+	return cache.New(o)
+}
+
+var reSearchLanguage = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// initSearchLanguages returns the Postgres text search configurations (languages) to search in.
+func initSearchLanguages(db *sqlx.DB, ko *koanf.Koanf) []string {
+	out := ko.Strings("site.search_languages")
+	if len(out) == 0 {
+		out = []string{"english"}
+	}
+
+	// The names are interpolated into SQL queries.
+	for _, l := range out {
+		if !reSearchLanguage.MatchString(l) {
+			lo.Fatalf("invalid language in site.search_languages: %s", l)
+		}
+	}
+
+	var found []string
+	if err := db.Select(&found, `SELECT cfgname FROM pg_ts_config WHERE cfgname = ANY($1)`, pq.Array(out)); err != nil {
+		lo.Fatalf("error checking text search configurations: %v", err)
+	}
+	for _, l := range out {
+		if !slices.Contains(found, l) {
+			lo.Fatalf("unknown text search configuration in site.search_languages: %s", l)
+		}
+	}
+
+	return out
+}
+
 func initCrawl(sc crawl.Schema, co *core.Core, ko *koanf.Koanf) *crawl.Crawl {
 	opt := crawl.Opt{
 		Workers:         ko.MustInt("crawl.workers"),
@@ -232,6 +312,15 @@ func initCrawl(sc crawl.Schema, co *core.Core, ko *koanf.Koanf) *crawl.Crawl {
 	return crawl.New(&opt, sc, nil, co, lo)
 }
 
+func initMaintenance(co *core.Core, ko *koanf.Koanf) *maintenance.Maintenance {
+	opt := maintenance.Opt{
+		Views:    core.MaterializedViews,
+		Interval: ko.Duration("maintenance.refresh_interval"),
+	}
+
+	return maintenance.New(&opt, co, lo)
+}
+
 func initPaginator(ko *koanf.Koanf) *paginator.Paginator {
 	perPage := ko.MustInt("site.listings_per_page")
 	pgOpt := paginator.Default()
@@ -240,8 +329,22 @@ func initPaginator(ko *koanf.Koanf) *paginator.Paginator {
 	return paginator.New(pgOpt)
 }
 
-func initSchema(ko *koanf.Koanf) crawl.Schema {
-	// SPDX license index.
+func initOGImg(ko *koanf.Koanf) *ogimg.Gen {
+	dir := ko.String("site.og_image_dir")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "portal-og")
+	}
+
+	g, err := ogimg.New(dir)
+	if err != nil {
+		lo.Fatalf("error initializing og image generator: %v", err)
+	}
+
+	return g
+}
+
+// initLicenses loads the SPDX license index (ID => name).
+func initLicenses(ko *koanf.Koanf) map[string]string {
 	licenses := make(map[string]string)
 	if b, err := os.ReadFile(ko.MustString("data_files.spdx")); err != nil {
 		log.Fatalf("error reading spdx file: %v", err)
@@ -262,6 +365,10 @@ func initSchema(ko *koanf.Koanf) crawl.Schema {
 		}
 	}
 
+	return licenses
+}
+
+func initSchema(licenses map[string]string, ko *koanf.Koanf) crawl.Schema {
 	// Programming language list.
 	langs := make(map[string]string)
 	if b, err := os.ReadFile(ko.MustString("data_files.languages")); err != nil {
@@ -388,6 +495,9 @@ func initSiteTemplates(dirPath string, wellKnownURI string) *template.Template {
 
 		// Format numbers with thousands separators
 		"formatNumber": formatNumber,
+
+		// URL of a tag's page.
+		"tagURL": tagURL,
 	})
 
 	// Parse all HTML files that match the pattern
@@ -444,6 +554,11 @@ func (s *Schema) ParseManifest(b []byte, manifestURL string, checkProvenance boo
 		return models.ManifestData{}, err
 	}
 
+	// Channels and plans are upserted by their GUIDs, which the schema doesn't fully check.
+	if err := validateFundingGUIDs(scm.Funding); err != nil {
+		return models.ManifestData{}, err
+	}
+
 	// Convert v1.Manifest to models.ManifestData
 	return models.ManifestData{
 		Version:  scm.Version,
@@ -454,3 +569,24 @@ func (s *Schema) ParseManifest(b []byte, manifestURL string, checkProvenance boo
 		URL:      v1.URL{URL: scm.URL.URL, URLobj: scm.URL.URLobj},
 	}, nil
 }
+
+// validateFundingGUIDs checks that the GUIDs of the channels and plans in a manifest are unique.
+func validateFundingGUIDs(f v1.Funding) error {
+	ids := make(map[string]struct{}, len(f.Channels))
+	for _, c := range f.Channels {
+		if _, ok := ids[c.GUID]; ok {
+			return fmt.Errorf("channels[].guid must be unique: %s", c.GUID)
+		}
+		ids[c.GUID] = struct{}{}
+	}
+
+	ids = make(map[string]struct{}, len(f.Plans))
+	for _, p := range f.Plans {
+		if _, ok := ids[p.GUID]; ok {
+			return fmt.Errorf("plans[].guid must be unique: %s", p.GUID)
+		}
+		ids[p.GUID] = struct{}{}
+	}
+
+	return nil
+}
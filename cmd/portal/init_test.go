@@ -3,6 +3,7 @@ package main
 import (
 	"testing"
 
+	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -59,3 +60,20 @@ func TestFormatNumber(t *testing.T) {
 		assert.Equal(t, tt.expected, result, "formatNumber(%v) should equal %s", tt.input, tt.expected)
 	}
 }
+
+func TestValidateFundingGUIDs(t *testing.T) {
+	f := v1.Funding{
+		Channels: v1.Channels{{GUID: "bank"}, {GUID: "paypal"}},
+		Plans:    v1.Plans{{GUID: "monthly"}, {GUID: "yearly"}},
+	}
+	assert.NoError(t, validateFundingGUIDs(f))
+
+	// Duplicate plan GUID.
+	f.Plans = append(f.Plans, v1.Plan{GUID: "monthly"})
+	assert.ErrorContains(t, validateFundingGUIDs(f), "plans[].guid must be unique")
+
+	// Duplicate channel GUID that isn't next to the other one.
+	f.Plans = f.Plans[:2]
+	f.Channels = append(f.Channels, v1.Channel{GUID: "bank"})
+	assert.ErrorContains(t, validateFundingGUIDs(f), "channels[].guid must be unique")
+}
@@ -32,6 +32,13 @@ func install(ver string, prompt bool, app *App, ko *koanf.Koanf) {
 
 	installDB(ver, app)
 
+	// Import the default exchange rates.
+	if f := ko.String("data_files.exchange_rates"); f != "" {
+		if err := importRates(initCore(app.fs, app.db, ko), f); err != nil {
+			app.lo.Printf("error importing exchange rates: %v", err)
+		}
+	}
+
 	app.lo.Println("done")
 }
 
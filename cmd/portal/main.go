@@ -1,12 +1,15 @@
 package main
 
 import (
+	"html/template"
 	"log"
 	"os"
-	"text/template"
+	"time"
 
 	"github.com/floss-fund/portal/internal/core"
 	"github.com/floss-fund/portal/internal/crawl"
+	"github.com/floss-fund/portal/internal/maintenance"
+	"github.com/floss-fund/portal/internal/ogimg"
 	"github.com/jmoiron/sqlx"
 	"github.com/knadh/koanf/v2"
 	"github.com/knadh/paginator/v2"
@@ -34,8 +37,17 @@ type Consts struct {
 
 	HomeNumTags     int `json:"site.home_num_tags"`
 	HomeNumProjects int `json:"site.home_num_projects"`
+	FeedNumItems    int `json:"site.feed_num_items"`
 
 	DumpFileName string `json:"site.dump_filename"`
+
+	EmbedFrameAncestors []string `json:"site.embed_frame_ancestors"`
+	DisplayCurrencies   []string `json:"site.display_currencies"`
+
+	AutocompleteTimeout time.Duration `json:"site.autocomplete_timeout"`
+
+	// The mode the app is running in (site, crawl ...).
+	Mode string `json:"mode"`
 }
 
 // App contains the "global" components that are passed around, especially through HTTP handlers.
@@ -44,8 +56,13 @@ type App struct {
 	siteTpl *template.Template
 	core    *core.Core
 	crawl   *crawl.Crawl
+	maint   *maintenance.Maintenance
 	schema  crawl.Schema
 	pg      *paginator.Paginator
+	ogImg   *ogimg.Gen
+
+	// SPDX license ID => name.
+	licenses map[string]string
 
 	db *sqlx.DB
 	fs stuffbin.FileSystem
@@ -94,11 +111,31 @@ func main() {
 	checkUpgrade(db)
 
 	// Initialize queries and data handler.
-	app.core = initCore(app.fs, db)
-	app.schema = initSchema(ko)
+	app.core = initCore(app.fs, db, ko)
+	app.licenses = initLicenses(ko)
+	app.schema = initSchema(app.licenses, ko)
 	app.crawl = initCrawl(app.schema, app.core, ko)
+	app.maint = initMaintenance(app.core, ko)
 	app.pg = initPaginator(ko)
 
+	// Import exchange rates.
+	if f := ko.String("import-rates"); f != "" {
+		if err := importRates(app.core, f); err != nil {
+			lo.Fatal(err)
+		}
+		return
+	}
+
+	// Serve metrics on a separate address while the site or the crawler runs.
+	if addr, mode := ko.String("metrics.address"), ko.String("mode"); addr != "" && (mode == "site" || mode == "crawl") {
+		go func() {
+			lo.Printf("serving metrics on %s", addr)
+			if err := initMetricsServer(app).Start(addr); err != nil {
+				lo.Printf("error starting metrics server: %v", err)
+			}
+		}()
+	}
+
 	// Run the crawl mode.
 	switch ko.String("mode") {
 	case "crawl":
@@ -107,9 +144,20 @@ func main() {
 	case "dump":
 		dumpManifests(app.core, lo)
 		return
+	case "maintenance":
+		if err := app.maint.Run(); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Refresh aggregate views in the background.
+	if ko.Duration("maintenance.refresh_interval") > 0 {
+		go app.maint.Schedule()
 	}
 
 	// Initialize the echo HTTP server.
+	app.ogImg = initOGImg(ko)
 	srv := initHTTPServer(app, ko)
 
 	lo.Printf("starting server on %s", ko.MustString("app.address"))
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/floss-fund/portal/internal/ogimg"
+	"github.com/labstack/echo/v4"
+)
+
+// ldObj is a schema.org JSON-LD object.
+type ldObj map[string]any
+
+// makeEntityLD returns the schema.org Person or Organization object for a manifest's entity.
+func makeEntityLD(m models.ManifestData, rootURL string) ldObj {
+	typ := "Organization"
+	if m.Entity.Type == "individual" {
+		typ = "Person"
+	}
+
+	out := ldObj{
+		"@type":       typ,
+		"@id":         fmt.Sprintf("%s/view/%s", rootURL, m.GUID),
+		"name":        m.Entity.Name,
+		"description": m.Entity.Description,
+		"url":         m.Entity.WebpageURLStr,
+		"email":       m.Entity.Email,
+	}
+	if m.Entity.Phone != "" {
+		out["telephone"] = m.Entity.Phone
+	}
+
+	return out
+}
+
+// makeProjectLD returns the schema.org SoftwareSourceCode object for a project.
+func makeProjectLD(p models.Project, m models.ManifestData, rootURL string) ldObj {
+	licenses := make([]string, 0, len(p.Licenses))
+	for _, l := range p.Licenses {
+		licenses = append(licenses, licenseURL(l))
+	}
+
+	return ldObj{
+		"@type":          "SoftwareSourceCode",
+		"@id":            fmt.Sprintf("%s/view/project/%s", rootURL, p.GUID),
+		"name":           p.Name,
+		"description":    p.Description,
+		"url":            p.WebpageURLStr,
+		"codeRepository": p.RepositoryURLStr,
+		"license":        licenses,
+		"keywords":       strings.Join(p.Tags, ", "),
+		"maintainer":     ldObj{"@id": fmt.Sprintf("%s/view/%s", rootURL, m.GUID)},
+	}
+}
+
+// makeFundingLD returns the schema.org entity object with its funding plans as Offers.
+func makeFundingLD(m models.ManifestData, rootURL string) ldObj {
+	offers := make([]ldObj, 0, len(m.Funding.Plans))
+	for _, p := range m.Funding.Plans {
+		if p.Status != "active" {
+			continue
+		}
+
+		offers = append(offers, ldObj{
+			"@type":         "Offer",
+			"name":          p.Name,
+			"description":   p.Description,
+			"price":         p.Amount,
+			"priceCurrency": p.Currency,
+			"url":           fmt.Sprintf("%s/view/funding/%s#plan-%s", rootURL, m.GUID, p.GUID),
+			"priceSpecification": ldObj{
+				"@type":         "UnitPriceSpecification",
+				"price":         p.Amount,
+				"priceCurrency": p.Currency,
+				"unitText":      p.Frequency,
+			},
+		})
+	}
+
+	out := makeEntityLD(m, rootURL)
+	out["makesOffer"] = offers
+	return out
+}
+
+// makeLD wraps one or more JSON-LD objects into a @graph document that can be
+// injected into a <script type="application/ld+json"> tag.
+func makeLD(objs ...ldObj) template.JS {
+	b, err := json.Marshal(ldObj{
+		"@context": "https://schema.org",
+		"@graph":   objs,
+	})
+	if err != nil {
+		return ""
+	}
+
+	// json.Marshal escapes <, >, & so the output is safe to be embedded in <script>.
+	return template.JS(b)
+}
+
+// handleOGImage renders a PNG social preview card for an entity or a project.
+//
+// /og/@github.com/user.png, /og/@github.com/user.png?project=project-guid
+func handleOGImage(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		mGuid = strings.TrimSuffix(c.Param("*"), ".png")
+		pGuid = c.QueryParam("project")
+	)
+
+	m, err := app.core.GetManifest(0, mGuid, core.ManifestStatusActive)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Manifest not found.")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error fetching manifest.")
+	}
+
+	numPlans := 0
+	for _, p := range m.Funding.Plans {
+		if p.Status == "active" {
+			numPlans++
+		}
+	}
+
+	typ := m.Entity.Type
+	if typ != "" {
+		typ = strings.ToUpper(typ[:1]) + typ[1:]
+	}
+
+	// Entity card with the most frequent tags and license across its projects.
+	card := ogimg.Card{
+		Title:    m.Entity.Name,
+		Subtitle: fmt.Sprintf("%s · %d project(s)", typ, len(m.Projects)),
+		NumPlans: numPlans,
+	}
+	{
+		var tags, licenses []string
+		for _, p := range m.Projects {
+			tags = append(tags, p.Tags...)
+			licenses = append(licenses, p.Licenses...)
+		}
+		card.Tags = topValues(tags)
+		if l := topValues(licenses); len(l) > 0 {
+			card.License = l[0]
+		}
+	}
+
+	// Project card.
+	if pGuid != "" {
+		n := slices.IndexFunc(m.Projects, func(o models.Project) bool {
+			return o.GUID == mGuid+"/"+pGuid
+		})
+		if n < 0 {
+			return echo.NewHTTPError(http.StatusNotFound, "Project not found.")
+		}
+
+		p := m.Projects[n]
+		card.Title = p.Name
+		card.Subtitle = "by " + m.Entity.Name
+		card.Tags = p.Tags
+		card.License = ""
+		if len(p.Licenses) > 0 {
+			card.License = p.Licenses[0]
+		}
+	}
+
+	b, err := app.ogImg.Get(m.GUID+"/"+pGuid, m.UpdatedAt, card)
+	if err != nil {
+		app.lo.Printf("error generating og image: %s: %v", m.GUID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error generating image.")
+	}
+
+	c.Response().Header().Set("Cache-Control", "public, max-age=86400")
+	return c.Blob(http.StatusOK, "image/png", b)
+}
+
+// ogImageURL returns the URL of the social preview card of a manifest or one of its projects.
+func ogImageURL(rootURL string, m models.ManifestData, pGuid string) string {
+	u := fmt.Sprintf("%s/og/%s.png?v=%d", rootURL, m.GUID, m.UpdatedAt.Unix())
+	if pGuid != "" {
+		u += "&project=" + url.QueryEscape(pGuid)
+	}
+
+	return u
+}
+
+// topValues returns the unique values in a list sorted by their frequency.
+func topValues(vals []string) []string {
+	counts := make(map[string]int)
+	out := []string{}
+	for _, v := range vals {
+		if counts[v] == 0 {
+			out = append(out, v)
+		}
+		counts[v]++
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return counts[out[i]] > counts[out[j]]
+	})
+
+	return out
+}
+
+// licenseURL returns the SPDX URL for a license (spdx:MIT) in a manifest.
+func licenseURL(l string) string {
+	if id, ok := strings.CutPrefix(l, "spdx:"); ok {
+		return "https://spdx.org/licenses/" + id + ".html"
+	}
+
+	return l
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/labstack/echo/v4"
+)
+
+var manifestStatuses = []string{
+	core.ManifestStatusPending,
+	core.ManifestStatusActive,
+	core.ManifestStatusExpiring,
+	core.ManifestStatusDisabled,
+	core.ManifestStatusBlocked,
+}
+
+// initMetricsServer initializes an HTTP server that only serves /metrics and the health checks.
+func initMetricsServer(app *App) *echo.Echo {
+	srv := echo.New()
+	srv.HideBanner = true
+	srv.HidePort = true
+
+	srv.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("app", app)
+			return next(c)
+		}
+	})
+	srv.GET("/metrics", handleMetrics)
+	srv.GET("/health/live", handleHealthLive)
+	srv.GET("/health/ready", handleHealthReady)
+
+	return srv
+}
+
+// httpMetrics is a middleware that counts HTTP requests and records their
+// latency by the route (and not the URL) that handled them.
+func httpMetrics(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+
+		// Handle the error here to record the status that's sent.
+		if err := next(c); err != nil {
+			c.Error(err)
+		}
+
+		var (
+			route  = c.Path()
+			status = strconv.Itoa(c.Response().Status)
+		)
+		metrics.GetOrCreateCounter(fmt.Sprintf(`portal_http_requests_total{route=%q,method=%q,status=%q}`,
+			route, c.Request().Method, status)).Inc()
+		metrics.GetOrCreateSummary(fmt.Sprintf(`portal_http_request_duration_seconds{route=%q}`, route)).UpdateDuration(start)
+
+		return nil
+	}
+}
+
+// handleMetrics writes the metrics in the Prometheus text format.
+func handleMetrics(c echo.Context) error {
+	var (
+		app = c.Get("app").(*App)
+		w   = c.Response()
+	)
+
+	// Manifests by status, with the statuses that have none at 0.
+	counts := make(map[string]int, len(manifestStatuses))
+	for _, s := range manifestStatuses {
+		counts[s] = 0
+	}
+	res, err := app.core.CountManifestsByStatus()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error counting manifests.")
+	}
+	for _, r := range res {
+		counts[r.Value] = r.Count
+	}
+
+	w.Header().Set(echo.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	for _, s := range manifestStatuses {
+		metrics.WriteGaugeUint64(w, fmt.Sprintf(`portal_manifests{status=%q}`, s), uint64(counts[s]))
+	}
+
+	// Cache of reads.
+	st := app.core.GetCacheStats()
+	metrics.WriteCounterUint64(w, "portal_cache_hits_total", st.Hits)
+	metrics.WriteCounterUint64(w, "portal_cache_misses_total", st.Misses)
+	metrics.WriteCounterUint64(w, "portal_cache_evictions_total", st.Evictions)
+	metrics.WriteGaugeUint64(w, "portal_cache_items", uint64(st.Items))
+
+	metrics.WritePrometheus(w, true)
+
+	return nil
+}
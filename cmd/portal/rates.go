@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
+)
+
+// importRates imports currency exchange rates from a local file into the DB,
+// replacing the existing rates.
+func importRates(co *core.Core, fPath string) error {
+	b, err := os.ReadFile(fPath)
+	if err != nil {
+		return fmt.Errorf("error reading rates file: %v", err)
+	}
+
+	rates, err := parseRates(b, filepath.Ext(fPath))
+	if err != nil {
+		return fmt.Errorf("error parsing rates file %s: %v", fPath, err)
+	}
+
+	if err := co.ReplaceExchangeRates(rates); err != nil {
+		return err
+	}
+
+	lo.Printf("imported %d exchange rates from %s", len(rates), fPath)
+	return nil
+}
+
+// parseRates parses exchange rates from a JSON or a CSV file. All rates should
+// be relative to the same base currency, ie, the units of a currency per unit
+// of the base currency, where the base currency's rate is 1.
+//
+// JSON: {"base": "USD", "date": "2025-01-01", "rates": {"USD": 1, "EUR": 0.92}}
+// CSV: currency,rate (with an optional header row)
+func parseRates(b []byte, ext string) (models.ExchangeRates, error) {
+	out := models.ExchangeRates{}
+
+	switch strings.ToLower(ext) {
+	case ".json":
+		var f struct {
+			Base  string             `json:"base"`
+			Rates map[string]float64 `json:"rates"`
+		}
+		if err := json.Unmarshal(b, &f); err != nil {
+			return nil, err
+		}
+
+		for cur, r := range f.Rates {
+			out[strings.ToUpper(cur)] = r
+		}
+		if f.Base != "" {
+			out[strings.ToUpper(f.Base)] = 1
+		}
+
+	case ".csv":
+		r := csv.NewReader(bytes.NewReader(b))
+		r.FieldsPerRecord = 2
+		r.TrimLeadingSpace = true
+
+		for n := 1; ; n++ {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			rate, err := strconv.ParseFloat(strings.TrimSpace(rec[1]), 64)
+			if err != nil {
+				// Skip the header.
+				if n == 1 {
+					continue
+				}
+				return nil, fmt.Errorf("invalid rate on line %d: %s", n, rec[1])
+			}
+			out[strings.ToUpper(strings.TrimSpace(rec[0]))] = rate
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown file type '%s'. Should be .json or .csv", ext)
+	}
+
+	if len(out) == 0 {
+		return nil, errors.New("no rates found")
+	}
+	for cur, r := range out {
+		if !reCurrency.MatchString(cur) {
+			return nil, fmt.Errorf("invalid currency: %s", cur)
+		}
+		if r <= 0 {
+			return nil, fmt.Errorf("invalid rate for %s: %v", cur, r)
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRates(t *testing.T) {
+	r, err := parseRates([]byte(`{"base": "usd", "rates": {"EUR": 0.5, "inr": 80}}`), ".json")
+	assert.NoError(t, err)
+	assert.Equal(t, models.ExchangeRates{"USD": 1, "EUR": 0.5, "INR": 80}, r)
+
+	r, err = parseRates([]byte("currency,rate\nUSD,1\nEUR, 0.5\n"), ".CSV")
+	assert.NoError(t, err)
+	assert.Equal(t, models.ExchangeRates{"USD": 1, "EUR": 0.5}, r)
+
+	_, err = parseRates([]byte("USD,1\nEUR,abc\n"), ".csv")
+	assert.Error(t, err)
+
+	_, err = parseRates([]byte(`{"rates": {"EUR": -1}}`), ".json")
+	assert.Error(t, err)
+
+	_, err = parseRates([]byte(`{"rates": {"EURO": 1}}`), ".json")
+	assert.Error(t, err)
+
+	_, err = parseRates([]byte(``), ".txt")
+	assert.Error(t, err)
+
+	// Conversion.
+	assert.Equal(t, 200.0, r.Convert(100, "EUR", "USD"))
+	assert.Equal(t, 100.0, r.Convert(100, "EUR", "XYZ"))
+	assert.False(t, r.CanConvert("EUR", "XYZ"))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,9 +12,11 @@ import (
 	"os"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/altcha-org/altcha-lib-go"
 	"github.com/floss-fund/go-funding-json/common"
@@ -77,12 +80,25 @@ type Page struct {
 	EnableCaptcha bool
 	ErrMessage    string
 	Message       string
+
+	// Canonical URL, Open Graph, and schema.org JSON-LD metadata.
+	URL    string
+	Image  string
+	OGType string
+	JSONLD template.JS
 }
 
 var (
-	orderByFields = []string{"created_at", "updated_at", "name"}
+	// Fields that the browse listings of each type can be sorted by.
+	orderByFields = map[string][]string{
+		"entities": {core.EntityOrderCreated, core.EntityOrderUpdated, core.EntityOrderName,
+			core.EntityOrderProjects, core.EntityOrderPlans, core.EntityOrderFunding},
+		"projects": {core.ProjectOrderCreated, core.ProjectOrderUpdated, core.ProjectOrderName,
+			core.ProjectOrderEntity, core.ProjectOrderLicense, core.ProjectOrderCrawled},
+	}
 
 	reMultiLines = regexp.MustCompile(`\n\n+`)
+	reCurrency   = regexp.MustCompile(`^[A-Z]{3}$`)
 	errCaptcha   = errors.New("invalid captcha")
 
 	browseTabs = []Tab{
@@ -96,6 +112,16 @@ var (
 			Label: "Entities",
 			URL:   "/browse/entities",
 		},
+		{
+			ID:    "tags",
+			Label: "Tags",
+			URL:   "/tags",
+		},
+		{
+			ID:    "licenses",
+			Label: "Licenses",
+			URL:   "/licenses",
+		},
 		{
 			ID:    "export",
 			Label: "Export",
@@ -325,11 +351,19 @@ func handleManifestPage(c echo.Context) error {
 		// Project guid.
 		pGuid = ""
 
+		// Currency to convert funding amounts to.
+		in = strings.ToUpper(c.QueryParam("in"))
+
 		// Template response.
 		out = struct {
 			Page
 			Manifest models.ManifestData
 			Project  models.Project
+
+			// Currency conversion.
+			Rates             models.ExchangeRates
+			In                string
+			DisplayCurrencies []string
 		}{}
 	)
 
@@ -410,6 +444,19 @@ func handleManifestPage(c echo.Context) error {
 		out.Description = abbrev(prj.Description, 200)
 	}
 
+	// Convert amounts on the funding and history pages if a currency is requested.
+	if in != "" && (tpl == "funding" || tpl == "history") {
+		rates, err := app.core.GetExchangeRates()
+		if err != nil {
+			return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching exchange rates.")
+		}
+		if _, ok := rates[in]; ok {
+			out.Rates = rates
+			out.In = in
+		}
+	}
+	out.DisplayCurrencies = app.consts.DisplayCurrencies
+
 	out.Manifest = m
 	out.Project = prj
 	out.Title = fmt.Sprintf(out.Title, m.Entity.Name)
@@ -442,6 +489,22 @@ func handleManifestPage(c echo.Context) error {
 		},
 	}
 
+	// Structured data for the page.
+	out.OGType = "website"
+	out.Image = ogImageURL(app.consts.RootURL, m, pGuid)
+	switch tpl {
+	case "projects":
+		objs := []ldObj{makeEntityLD(m, app.consts.RootURL)}
+		for _, p := range m.Projects {
+			objs = append(objs, makeProjectLD(p, m, app.consts.RootURL))
+		}
+		out.JSONLD = makeLD(objs...)
+	case "funding":
+		out.JSONLD = makeLD(makeFundingLD(m, app.consts.RootURL))
+	default:
+		out.JSONLD = makeLD(makeEntityLD(m, app.consts.RootURL))
+	}
+
 	// If the view is for a single project, add a tab for that too.
 	if pGuid != "" {
 		out.Title = fmt.Sprintf("%s by %s - Funding", prj.Name, m.Entity.Name)
@@ -451,69 +514,73 @@ func handleManifestPage(c echo.Context) error {
 			ID:       "project",
 			Selected: true,
 			Label:    prj.Name,
-			URL:      fmt.Sprintf("%s%sprojects/%s/%s", app.consts.RootURL, prefix, m.GUID, prj.GUID),
+			URL:      fmt.Sprintf("%s%sproject/%s", app.consts.RootURL, prefix, prj.GUID),
 		})
+		out.JSONLD = makeLD(makeProjectLD(prj, m, app.consts.RootURL), makeEntityLD(m, app.consts.RootURL))
+	}
 
-		return c.Render(http.StatusOK, tpl, out)
+	// The selected tab's URL is the canonical URL of the page.
+	for _, t := range out.Tabs {
+		if t.Selected {
+			out.URL = t.URL
+		}
 	}
 
 	return c.Render(http.StatusOK, tpl, out)
 }
 
-func handleSearchPage(c echo.Context) error {
-	var (
-		app      = c.Get("app").(*App)
-		query    = c.QueryParam("q")
-		typ      = c.QueryParam("type")
-		tags     = c.QueryParams()["tag"]
-		licenses = c.QueryParams()["license"]
-		order    = strings.ToUpper(c.QueryParam("order"))
-		orderBy  = c.QueryParam("order_by")
-
-		pg = app.pg.NewFromURL(c.Request().URL.Query())
-	)
+// searchQuery represents the search params in a search request.
+type searchQuery struct {
+	core.SearchFilter
 
-	// Sanitize search fields.
-	query = strings.TrimSpace(query)
-	if ((query == "" || len(query) > 128) && len(tags) == 0 && len(licenses) == 0) ||
-		len(tags) > 5 || len(licenses) > 5 {
-		return c.Redirect(http.StatusTemporaryRedirect, app.consts.RootURL)
-	}
+	Type    string
+	OrderBy string
+	Order   string
+}
 
-	if order != "" && order != "ASC" && order != "DESC" {
-		order = "ASC"
-	}
+// Number of values shown for each search facet.
+const numFacetValues = 20
 
-	// Do the search.
+// facetParams maps search facets to the query params that filter them and their labels.
+var facetParams = map[string]struct{ param, label string }{
+	core.FacetTags:         {"tag", "Tags"},
+	core.FacetLicenses:     {"license", "Licenses"},
+	core.FacetEntityTypes:  {"entity_type", "Entity type"},
+	core.FacetEntityRoles:  {"entity_role", "Entity role"},
+	core.FacetChannelTypes: {"channel", "Accepts"},
+}
+
+// searchTypes are the types of results that a search can be narrowed down to.
+// "all" searches projects and entities together.
+var searchTypes = []string{"all", "project", "entity"}
+
+// errEmptySearch is returned when a search request has neither a query nor any filters.
+var errEmptySearch = errors.New("empty search")
+
+func handleSearchPage(c echo.Context) error {
 	var (
-		results any
-		total   int
+		app = c.Get("app").(*App)
+		pg  = app.pg.NewFromURL(c.Request().URL.Query())
 	)
-	switch typ {
-	case "entity":
-		res, err := app.core.SearchEntities(query, pg.Offset, pg.Limit)
-		if err != nil {
-			return errPage(c, http.StatusBadRequest, "", "Error", "An internal error occurred while searching.")
-		}
 
-		results = res
-		if len(res) > 0 {
-			total = res[0].Total
+	q, err := parseSearchQuery(c)
+	if err != nil {
+		if err == errEmptySearch {
+			return c.Redirect(http.StatusTemporaryRedirect, app.consts.RootURL)
 		}
-	case "project":
-		tags := append([]string{}, tags...)
-		licenses := append([]string{}, licenses...)
+		return errPage(c, http.StatusBadRequest, "", "Error", err.Error())
+	}
 
-		res, err := app.core.SearchProjects(query, tags, licenses, orderBy, order, pg.Offset, pg.Limit)
-		if err != nil {
-			return errPage(c, http.StatusBadRequest, "", "Error", "An internal error occurred while searching.")
-		}
-		results = res
-		if len(res) > 0 {
-			total = res[0].Total
-		}
-	default:
-		return errPage(c, http.StatusBadRequest, "", "Error", "Unknown type.")
+	// Do the search.
+	results, counts, err := doSearch(app, q, pg.Offset, pg.Limit)
+	if err != nil {
+		return errPage(c, http.StatusBadRequest, "", "Error", "An internal error occurred while searching.")
+	}
+	total := counts.Projects + counts.Entities
+
+	facets, err := getSearchFacets(app, q)
+	if err != nil {
+		return errPage(c, http.StatusBadRequest, "", "Error", "An internal error occurred while searching.")
 	}
 
 	pg.SetTotal(total)
@@ -522,33 +589,400 @@ func handleSearchPage(c echo.Context) error {
 		Page
 		Pagination                   template.HTML
 		QueryType, Query, QueryField string
+		Suggestion, SuggestionURL    string
+		Plan                         core.PlanFilter
+		Facets                       []facetGroup
+		DisplayCurrencies            []string
 		Total                        int
 		Results                      any
 	}{}
 
 	// Additional query params to attach to paginated URLs.
 	qp := url.Values{}
-	qp.Set("q", query)
-	qp.Set("type", typ)
+	for _, k := range []string{"q", "type", "tag", "license", "min_amount", "max_amount", "currency", "frequency", "plan_status", "in", "channel", "entity_type", "entity_role", "order_by", "order"} {
+		if v, ok := c.QueryParams()[k]; ok {
+			qp[k] = v
+		}
+	}
 
-	heading := abbrev(query, 50)
+	heading := abbrev(q.Query, 50)
 	if heading != "" {
 		heading = fmt.Sprintf(`Search "%s"`, heading)
-	} else if heading == "" && len(tags) > 0 {
-		heading = "#" + strings.Join(tags, ", ")
+	} else if heading == "" && len(q.Tags) > 0 {
+		heading = "#" + strings.Join(q.Tags, ", ")
 	}
 
 	out.Pagination = template.HTML(pg.HTML("", qp))
 	out.Title = "Search"
 	out.Heading = heading
-	out.QueryType = typ
-	out.Query = query
+	out.Tabs = makeSearchTabs(c, q.Type, counts)
+	out.QueryType = q.Type
+	out.Query = q.Query
+	out.Plan = q.Plan
+	out.Facets = makeFacetGroups(c, q, facets)
+	if out.Suggestion = getSearchSuggestion(app, q, total); out.Suggestion != "" {
+		sq := c.Request().URL.Query()
+		sq.Set("q", out.Suggestion)
+		sq.Del("page")
+		out.SuggestionURL = "/search?" + sq.Encode()
+	}
+	out.DisplayCurrencies = app.consts.DisplayCurrencies
 	out.Total = total
 	out.Results = results
 
 	return c.Render(http.StatusOK, "search", out)
 }
 
+// handleSearch is the public search API that takes the same params as the search page.
+func handleSearch(c echo.Context) error {
+	var (
+		app = c.Get("app").(*App)
+		pg  = app.pg.NewFromURL(c.Request().URL.Query())
+	)
+
+	q, err := parseSearchQuery(c)
+	if err != nil {
+		if err == errEmptySearch {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid search query.")
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	results, counts, err := doSearch(app, q, pg.Offset, pg.Limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error searching.")
+	}
+	total := counts.Projects + counts.Entities
+
+	facets, err := getSearchFacets(app, q)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error searching.")
+	}
+
+	return c.JSON(http.StatusOK, okResp{struct {
+		Results    any                       `json:"results"`
+		Facets     map[string][]models.Facet `json:"facets"`
+		Suggestion string                    `json:"suggestion"`
+		Counts     models.SearchCounts       `json:"counts"`
+		Total      int                       `json:"total"`
+		Page       int                       `json:"page"`
+		PerPage    int                       `json:"per_page"`
+	}{results, facets, getSearchSuggestion(app, q, total), counts, total, pg.Page, pg.PerPage}})
+}
+
+// Number of autocomplete suggestions of each type (projects, entities, tags).
+const numAutocomplete = 5
+
+// handleAutocomplete returns project names, entity names, and tags matching a partial search query.
+// htmx requests get the rendered suggestions list and others get JSON.
+func handleAutocomplete(c echo.Context) error {
+	var (
+		app = c.Get("app").(*App)
+		q   = strings.TrimSpace(c.QueryParam("q"))
+		out = models.Autocomplete{}
+	)
+
+	if n := utf8.RuneCountInString(q); n >= 2 && n <= 64 {
+		// Suggestions are only useful if they're fast. On timeouts or errors, they're skipped.
+		ctx, cancel := context.WithTimeout(c.Request().Context(), app.consts.AutocompleteTimeout)
+		defer cancel()
+
+		out, _ = app.core.GetAutocomplete(ctx, q, numAutocomplete)
+		for n, r := range out.Projects {
+			out.Projects[n].URL = app.consts.RootURL + "/view/project/" + r.GUID
+		}
+		for n, r := range out.Entities {
+			out.Entities[n].URL = app.consts.RootURL + "/view/" + r.GUID
+		}
+		for n, r := range out.Tags {
+			out.Tags[n].URL = app.consts.RootURL + tagURL(r.GUID)
+		}
+	}
+
+	if c.Request().Header.Get("HX-Request") == "true" {
+		return c.Render(http.StatusOK, "autocomplete", out)
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// parseSearchQuery reads and validates the search params in a request.
+func parseSearchQuery(c echo.Context) (searchQuery, error) {
+	var (
+		qp = c.QueryParams()
+		q  = searchQuery{
+			SearchFilter: core.SearchFilter{
+				Query:    strings.TrimSpace(c.QueryParam("q")),
+				Tags:     append([]string{}, qp["tag"]...),
+				Licenses: append([]string{}, qp["license"]...),
+			},
+			Type:    c.QueryParam("type"),
+			OrderBy: c.QueryParam("order_by"),
+			Order:   strings.ToUpper(c.QueryParam("order")),
+		}
+	)
+
+	if q.Type == "" {
+		q.Type = "all"
+	}
+	if !slices.Contains(searchTypes, q.Type) {
+		return q, errors.New("Unknown type.")
+	}
+
+	// Funding plan filters.
+	for _, f := range []struct {
+		key string
+		val *float64
+	}{{"min_amount", &q.Plan.MinAmount}, {"max_amount", &q.Plan.MaxAmount}} {
+		v := strings.TrimSpace(c.QueryParam(f.key))
+		if v == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("Invalid %s.", strings.ReplaceAll(f.key, "_", " "))
+		}
+		*f.val = n
+	}
+	if q.Plan.MaxAmount > 0 && q.Plan.MinAmount > q.Plan.MaxAmount {
+		return q, errors.New("Min amount should be less than max amount.")
+	}
+
+	q.Plan.Currency = strings.ToUpper(strings.TrimSpace(c.QueryParam("currency")))
+	if q.Plan.Currency != "" && !reCurrency.MatchString(q.Plan.Currency) {
+		return q, errors.New("Invalid currency.")
+	}
+
+	for _, f := range qp["frequency"] {
+		if f == "" {
+			continue
+		}
+		if !slices.Contains(v1.PlanFrequencies, f) {
+			return q, errors.New("Invalid plan frequency.")
+		}
+		q.Plan.Frequencies = append(q.Plan.Frequencies, f)
+	}
+
+	q.Plan.Status = c.QueryParam("plan_status")
+	if q.Plan.Status != "" && !slices.Contains(v1.PlanStatuses, q.Plan.Status) {
+		return q, errors.New("Invalid plan status.")
+	}
+
+	// Currency that the amount filters are in. Plan amounts in other currencies
+	// are converted to it. Sorting by amount always requires a common currency.
+	q.Plan.AmountCurrency = strings.ToUpper(strings.TrimSpace(c.QueryParam("in")))
+	if q.Plan.AmountCurrency != "" && !reCurrency.MatchString(q.Plan.AmountCurrency) {
+		return q, errors.New("Invalid currency.")
+	}
+	if q.OrderBy == "amount" && q.Plan.AmountCurrency == "" {
+		q.Plan.AmountCurrency = c.Get("app").(*App).consts.DisplayCurrencies[0]
+	}
+
+	channels, err := parseChannelTypes(qp["channel"])
+	if err != nil {
+		return q, err
+	}
+	q.Channels = channels
+
+	if q.EntityTypes, err = parseEnums(qp["entity_type"], v1.EntityTypes, "entity type"); err != nil {
+		return q, err
+	}
+	if q.EntityRoles, err = parseEnums(qp["entity_role"], v1.EntityRoles, "entity role"); err != nil {
+		return q, err
+	}
+
+	// Sanitize search fields. Tag, license, and plan filters apply only to projects.
+	hasFilters := len(q.Channels) > 0 || len(q.EntityTypes) > 0 || len(q.EntityRoles) > 0 ||
+		(q.Type != "entity" && (len(q.Tags) > 0 || len(q.Licenses) > 0 || !q.Plan.IsEmpty()))
+	if ((q.Query == "" || len(q.Query) > 128) && !hasFilters) || len(q.Tags) > 5 || len(q.Licenses) > 5 {
+		return q, errEmptySearch
+	}
+
+	if q.Order != "" && q.Order != "ASC" && q.Order != "DESC" {
+		q.Order = "ASC"
+	}
+
+	return q, nil
+}
+
+// doSearch runs a search and returns the results and the number of matches of each type.
+// The counts for types that aren't searched are zero.
+func doSearch(app *App, q searchQuery, offset, limit int) (any, models.SearchCounts, error) {
+	switch q.Type {
+	case "all":
+		return app.core.SearchAll(q.SearchFilter, offset, limit)
+
+	case "entity":
+		res, err := app.core.SearchEntities(q.SearchFilter, q.OrderBy, q.Order, offset, limit)
+		if err != nil {
+			return nil, models.SearchCounts{}, err
+		}
+
+		out := models.SearchCounts{}
+		if len(res) > 0 {
+			out.Entities = res[0].Total
+		}
+		return res, out, nil
+	}
+
+	res, err := app.core.SearchProjects(q.SearchFilter, q.OrderBy, q.Order, offset, limit)
+	if err != nil {
+		return nil, models.SearchCounts{}, err
+	}
+
+	out := models.SearchCounts{}
+	if len(res) > 0 {
+		out.Projects = res[0].Total
+	}
+	return res, out, nil
+}
+
+// makeSearchTabs returns tabs that switch the type of the current search. Counts are
+// shown on the tabs for which they are known, which is all of them in the "all" mode.
+func makeSearchTabs(c echo.Context, typ string, counts models.SearchCounts) []Tab {
+	tabs := []struct {
+		typ, label string
+		count      int
+	}{
+		{"all", "All", counts.Projects + counts.Entities},
+		{"project", "Projects", counts.Projects},
+		{"entity", "Entities", counts.Entities},
+	}
+
+	out := make([]Tab, 0, len(tabs))
+	for _, t := range tabs {
+		label := t.label
+		if typ == "all" || typ == t.typ {
+			label = fmt.Sprintf("%s (%d)", t.label, t.count)
+		}
+
+		// Sorts differ by type.
+		qp := c.Request().URL.Query()
+		qp.Set("type", t.typ)
+		qp.Del("page")
+		qp.Del("order_by")
+		qp.Del("order")
+
+		out = append(out, Tab{ID: t.typ, Label: label, URL: "/search?" + qp.Encode(), Selected: typ == t.typ})
+	}
+
+	return out
+}
+
+// getSearchFacets returns the facet counts for a search. Searches across all types get
+// the project facets as most filters (tags, licenses) apply only to projects.
+func getSearchFacets(app *App, q searchQuery) (map[string][]models.Facet, error) {
+	if q.Type == "entity" {
+		return app.core.GetEntityFacets(q.SearchFilter, core.EntityFacets, numFacetValues)
+	}
+
+	return app.core.GetProjectFacets(q.SearchFilter, core.ProjectFacets, numFacetValues)
+}
+
+// getSearchSuggestion returns a "did you mean" query for a search query with words
+// that match nothing, or an empty string. Suggestions are only looked up for searches
+// that returned no results.
+func getSearchSuggestion(app *App, q searchQuery, total int) string {
+	if q.Query == "" || total > 0 {
+		return ""
+	}
+
+	// Suggestions are not critical to the search. Errors are logged and ignored.
+	out, err := app.core.GetSearchSuggestion(q.Query)
+	if err != nil {
+		return ""
+	}
+
+	return out
+}
+
+// parseChannelTypes validates funding channel types in query params.
+func parseChannelTypes(vals []string) ([]string, error) {
+	return parseEnums(vals, v1.ChannelTypes, "channel type")
+}
+
+// parseEnums validates query param values against a list of allowed values.
+func parseEnums(vals, allowed []string, name string) ([]string, error) {
+	out := []string{}
+	for _, v := range vals {
+		if v == "" {
+			continue
+		}
+		if !slices.Contains(allowed, v) {
+			return nil, fmt.Errorf("Invalid %s.", name)
+		}
+		out = append(out, v)
+	}
+
+	return out, nil
+}
+
+// facetLink is a facet value with a URL that toggles the value in the current page's filters.
+type facetLink struct {
+	models.Facet
+	URL      string
+	Selected bool
+}
+
+// facetGroup is a facet's values with links that toggle them in the current search.
+type facetGroup struct {
+	Name  string
+	Label string
+	Links []facetLink
+}
+
+// makeFacetGroups returns the non-empty facets of a search in order with their links.
+func makeFacetGroups(c echo.Context, q searchQuery, facets map[string][]models.Facet) []facetGroup {
+	names := core.ProjectFacets
+	if q.Type == "entity" {
+		names = core.EntityFacets
+	}
+
+	out := make([]facetGroup, 0, len(names))
+	for _, n := range names {
+		if len(facets[n]) == 0 {
+			continue
+		}
+		out = append(out, makeFacetGroup(c, n, facets[n]))
+	}
+
+	return out
+}
+
+// makeFacetGroup returns a facet's values with their links.
+func makeFacetGroup(c echo.Context, name string, facets []models.Facet) facetGroup {
+	p := facetParams[name]
+	return facetGroup{Name: name, Label: p.label, Links: makeFacetLinks(c, p.param, facets)}
+}
+
+// makeFacetLinks returns facet values with links that add or remove the value
+// from the given query param in the current request's URL.
+func makeFacetLinks(c echo.Context, param string, facets []models.Facet) []facetLink {
+	var (
+		req = c.Request().URL
+		out = make([]facetLink, 0, len(facets))
+	)
+	for _, f := range facets {
+		qp := req.Query()
+		qp.Del("page")
+		qp.Del("after")
+		qp.Del("before")
+
+		cur := qp[param]
+		sel := slices.Contains(cur, f.Value)
+		if sel {
+			qp[param] = slices.DeleteFunc(slices.Clone(cur), func(v string) bool { return v == f.Value })
+		} else {
+			qp.Add(param, f.Value)
+		}
+
+		out = append(out, facetLink{Facet: f, URL: req.Path + "?" + qp.Encode(), Selected: sel})
+	}
+
+	return out
+}
+
 func handleBrowseEntitiesPage(c echo.Context) error {
 	return renderBrowsePage("entities", c)
 }
@@ -588,9 +1022,7 @@ func handleExportPage(c echo.Context) error {
 	out.Heading = "Export"
 	out.File = file
 
-	out.Tabs = make([]Tab, len(browseTabs))
-	copy(out.Tabs, browseTabs)
-	out.Tabs[2].Selected = true
+	out.Tabs = selectTab("export")
 
 	return c.Render(http.StatusOK, "export", out)
 }
@@ -649,85 +1081,194 @@ func (t *tplRenderer) Render(w io.Writer, name string, data any, c echo.Context)
 func renderBrowsePage(typ string, c echo.Context) error {
 	var app = c.Get("app").(*App)
 
-	// Get order by fields from the query.
-	var (
-		orderBy = "created_at"
-		order   = "desc"
-	)
-	if o := c.QueryParam("order_by"); o != "" {
-		for _, f := range orderByFields {
-			if f == o {
-				orderBy = o
-				break
-			}
-		}
+	q, err := parseListQuery(c, typ)
+	if err != nil {
+		return errPage(c, http.StatusBadRequest, "", "Error", err.Error())
 	}
-	if o := c.QueryParam("order"); o != "" && (o == "asc" || o == "desc") {
-		order = o
+
+	results, cur, err := getListPage(app, typ, q)
+	if err == core.ErrInvalidCursor {
+		return errPage(c, http.StatusBadRequest, "", "Error", "Invalid cursor.")
+	} else if err != nil {
+		return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching results.")
+	}
+
+	total, estimated, err := countListing(app, typ, q.Channels)
+	if err != nil {
+		return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching results.")
+	}
+
+	// Channel facets over the whole listing.
+	var f map[string][]models.Facet
+	if typ == "entities" {
+		f, err = app.core.GetEntityFacets(core.SearchFilter{}, []string{core.FacetChannelTypes}, numFacetValues)
+	} else {
+		f, err = app.core.GetProjectFacets(core.SearchFilter{}, []string{core.FacetChannelTypes}, numFacetValues)
+	}
+	if err != nil {
+		return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching results.")
+	}
+
+	out := struct {
+		Page
+		Results                    any
+		ChannelFacets              facetGroup
+		Total                      int
+		Type                       string
+		FirstURL, PrevURL, NextURL string
+	}{}
+	out.Results = results
+	out.ChannelFacets = makeFacetGroup(c, core.FacetChannelTypes, f[core.FacetChannelTypes])
+	out.Total = total
+	out.Type = typ
+	out.Title = fmt.Sprintf("Browse %s", typ)
+	if estimated {
+		out.Heading = fmt.Sprintf("Browse %s (~%d)", typ, total)
+	} else {
+		out.Heading = fmt.Sprintf("Browse %s (%d)", typ, total)
+	}
+
+	// Links to the pages around this one keep the filters and the sort order.
+	if q.After != nil || q.Before != nil {
+		out.FirstURL = pageURL(c, "", "")
+	}
+	if cur.Prev != "" {
+		out.PrevURL = pageURL(c, "before", cur.Prev)
+	}
+	if cur.Next != "" {
+		out.NextURL = pageURL(c, "after", cur.Next)
+	}
+
+	out.Tabs = selectTab(typ)
+
+	return c.Render(http.StatusOK, "browse", out)
+}
+
+// handleListProjects is the public API for browsing projects with cursor pagination.
+func handleListProjects(c echo.Context) error {
+	return renderListAPI("projects", c)
+}
+
+// handleListEntities is the public API for browsing entities with cursor pagination.
+func handleListEntities(c echo.Context) error {
+	return renderListAPI("entities", c)
+}
+
+// renderListAPI returns a page of a listing as JSON. The total is only counted if it's requested (total=true).
+func renderListAPI(typ string, c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	q, err := parseListQuery(c, typ)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	results, cur, err := getListPage(app, typ, q)
+	if err == core.ErrInvalidCursor {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid cursor.")
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error fetching results.")
 	}
 
-	// Get the total count.
 	var (
-		results any
-		pg      = app.pg.NewFromURL(c.Request().URL.Query())
-		total   = 0
+		total     *int
+		estimated bool
 	)
-
-	switch typ {
-	case "entities":
-		res, err := app.core.GetEntities(orderBy, order, pg.Offset, pg.Limit)
+	if b, _ := strconv.ParseBool(c.QueryParam("total")); b {
+		n, e, err := countListing(app, typ, q.Channels)
 		if err != nil {
-			return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching results.")
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error fetching results.")
 		}
-		results = res
+		total, estimated = &n, e
+	}
 
-		if len(res) > 0 {
-			total = res[0].Total
-		}
+	return c.JSON(http.StatusOK, okResp{struct {
+		Results any `json:"results"`
+		core.PageCursors
+		Total          *int `json:"total,omitempty"`
+		TotalEstimated bool `json:"total_estimated,omitempty"`
+		PerPage        int  `json:"per_page"`
+	}{results, cur, total, estimated, q.Limit}})
+}
 
-	case "projects":
-		res, err := app.core.GetProjects(orderBy, order, pg.Offset, pg.Limit)
-		if err != nil {
-			return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching results.")
-		}
-		results = res
+// parseListQuery reads and validates the filters, sort order, and cursor params of a listing.
+func parseListQuery(c echo.Context, typ string) (core.ListQuery, error) {
+	q := core.ListQuery{
+		OrderBy: "created_at",
+		Order:   "desc",
+		Limit:   c.Get("app").(*App).pg.NewFromURL(c.Request().URL.Query()).Limit,
+	}
 
-		if len(res) > 0 {
-			total = res[0].Total
+	if o := c.QueryParam("order_by"); slices.Contains(orderByFields[typ], o) {
+		q.OrderBy = o
+	}
+	if o := c.QueryParam("order"); o == "asc" || o == "desc" {
+		q.Order = o
+	}
+
+	channels, err := parseChannelTypes(c.QueryParams()["channel"])
+	if err != nil {
+		return q, err
+	}
+	q.Channels = channels
+
+	if v := c.QueryParam("after"); v != "" {
+		if q.After, err = core.ParseCursor(v); err != nil {
+			return q, errors.New("Invalid cursor.")
+		}
+	} else if v := c.QueryParam("before"); v != "" {
+		if q.Before, err = core.ParseCursor(v); err != nil {
+			return q, errors.New("Invalid cursor.")
 		}
 	}
 
-	// Additional query params to attach to paginated URLs.
-	pg.SetTotal(total)
-	qp := url.Values{}
-	qp.Set("order_by", orderBy)
-	qp.Set("order", order)
+	return q, nil
+}
 
-	out := struct {
-		Page
-		Pagination template.HTML
-		Results    any
-		Total      int
-		Type       string
-	}{}
-	out.Pagination = template.HTML(pg.HTML("", qp))
-	out.Results = results
-	out.Total = total
-	out.Type = typ
-	out.Title = fmt.Sprintf("Browse %s - Page %d", typ, pg.Page)
-	out.Heading = fmt.Sprintf("Browse %s (%d)", typ, total)
+// getListPage returns a page of projects or entities and the cursors to the pages around it.
+func getListPage(app *App, typ string, q core.ListQuery) (any, core.PageCursors, error) {
+	if typ == "entities" {
+		return app.core.GetEntitiesPage(q)
+	}
 
-	out.Tabs = make([]Tab, len(browseTabs))
-	copy(out.Tabs, browseTabs)
+	return app.core.GetProjectsPage(q)
+}
 
-	switch typ {
-	case "projects":
-		out.Tabs[0].Selected = true
-	case "entities":
-		out.Tabs[1].Selected = true
+// countListing returns the (estimated if unfiltered and large) number of projects or entities in a listing.
+func countListing(app *App, typ string, channels []string) (int, bool, error) {
+	if typ == "entities" {
+		return app.core.CountEntities(channels)
 	}
 
-	return c.Render(http.StatusOK, "browse", out)
+	return app.core.CountProjects(channels)
+}
+
+// pageURL returns the current URL with the cursor param (after, before) set to a cursor.
+// Empty param returns the URL of the first page.
+func pageURL(c echo.Context, param, cursor string) string {
+	qp := c.Request().URL.Query()
+	qp.Del("after")
+	qp.Del("before")
+	qp.Del("page")
+	if param != "" {
+		qp.Set(param, cursor)
+	}
+
+	if len(qp) == 0 {
+		return c.Request().URL.Path
+	}
+	return c.Request().URL.Path + "?" + qp.Encode()
+}
+
+// selectTab returns a copy of the browse tabs with the given tab selected.
+func selectTab(id string) []Tab {
+	out := make([]Tab, len(browseTabs))
+	copy(out, browseTabs)
+	for n, t := range out {
+		out[n].Selected = t.ID == id
+	}
+
+	return out
 }
 
 func errPage(c echo.Context, code int, tpl, title, message string) error {
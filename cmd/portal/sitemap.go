@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// Number of manifests in a single child sitemap. Each manifest produces
+	// 4 entity pages, well within the 50,000 URL limit of a sitemap.
+	sitemapManifestsPerPage = 10000
+
+	// Range of project IDs in a single child sitemap of projects. Each project
+	// produces one page, so a range never exceeds the 50,000 URL limit.
+	sitemapProjectsPerPage = 50000
+)
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapLoc `xml:"url"`
+}
+
+// handleSitemapIndex renders the sitemap index that links to paginated child sitemaps
+// of manifests and projects.
+func handleSitemapIndex(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	total, err := app.core.CountManifests(core.ManifestStatusActive)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error generating sitemap.")
+	}
+
+	maxID, err := app.core.GetMaxProjectID()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error generating sitemap.")
+	}
+
+	// At least one sitemap with the static pages.
+	var (
+		numPages    = max(1, (total+sitemapManifestsPerPage-1)/sitemapManifestsPerPage)
+		numPrjPages = (maxID + sitemapProjectsPerPage - 1) / sitemapProjectsPerPage
+	)
+
+	out := sitemapIndex{Sitemaps: make([]sitemapLoc, 0, numPages+numPrjPages)}
+	for n := 1; n <= numPages; n++ {
+		out.Sitemaps = append(out.Sitemaps, sitemapLoc{Loc: fmt.Sprintf("%s/sitemap/%d.xml", app.consts.RootURL, n)})
+	}
+	for n := 1; n <= numPrjPages; n++ {
+		out.Sitemaps = append(out.Sitemaps, sitemapLoc{Loc: fmt.Sprintf("%s/sitemap/projects/%d.xml", app.consts.RootURL, n)})
+	}
+
+	b, _ := xml.Marshal(out)
+	return c.XMLBlob(http.StatusOK, append([]byte(xml.Header), b...))
+}
+
+// handleSitemap renders a child sitemap with the entity, projects, funding,
+// and history page URLs of a page of manifests.
+func handleSitemap(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	page, err := strconv.Atoi(strings.TrimSuffix(c.Param("page"), ".xml"))
+	if err != nil || page < 1 {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown sitemap")
+	}
+
+	res, err := app.core.GetSitemapManifests((page-1)*sitemapManifestsPerPage, sitemapManifestsPerPage)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error generating sitemap.")
+	}
+	if len(res) == 0 && page > 1 {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown sitemap")
+	}
+
+	var (
+		root = app.consts.RootURL
+		out  = sitemapURLSet{}
+	)
+
+	// Static pages go in the first sitemap.
+	if page == 1 {
+		for _, u := range []string{"", "/browse/projects", "/browse/entities", "/submit", "/stats", "/tags", "/licenses"} {
+			out.URLs = append(out.URLs, sitemapLoc{Loc: root + u})
+		}
+	}
+
+	for _, m := range res {
+		mod := m.UpdatedAt.UTC().Format(time.RFC3339)
+
+		out.URLs = append(out.URLs,
+			sitemapLoc{Loc: fmt.Sprintf("%s/view/%s", root, m.GUID), LastMod: mod},
+			sitemapLoc{Loc: fmt.Sprintf("%s/view/projects/%s", root, m.GUID), LastMod: mod},
+			sitemapLoc{Loc: fmt.Sprintf("%s/view/funding/%s", root, m.GUID), LastMod: mod},
+			sitemapLoc{Loc: fmt.Sprintf("%s/view/history/%s", root, m.GUID), LastMod: mod},
+		)
+	}
+
+	b, _ := xml.Marshal(out)
+	return c.XMLBlob(http.StatusOK, append([]byte(xml.Header), b...))
+}
+
+// handleSitemapProjects renders a child sitemap with the single project page URLs
+// of the projects in a range of project IDs.
+func handleSitemapProjects(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	page, err := strconv.Atoi(strings.TrimSuffix(c.Param("page"), ".xml"))
+	if err != nil || page < 1 {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown sitemap")
+	}
+
+	maxID, err := app.core.GetMaxProjectID()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error generating sitemap.")
+	}
+
+	fromID := (page - 1) * sitemapProjectsPerPage
+	if fromID >= maxID {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown sitemap")
+	}
+
+	// Ranges whose projects have all been deleted produce an empty sitemap.
+	res, err := app.core.GetSitemapProjects(fromID, fromID+sitemapProjectsPerPage)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error generating sitemap.")
+	}
+
+	var (
+		root = app.consts.RootURL
+		out  = sitemapURLSet{URLs: make([]sitemapLoc, 0, len(res))}
+	)
+	for _, p := range res {
+		out.URLs = append(out.URLs, sitemapLoc{
+			Loc:     fmt.Sprintf("%s/view/project/%s/%s", root, p.ManifestGUID, p.GUID),
+			LastMod: p.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	b, _ := xml.Marshal(out)
+	return c.XMLBlob(http.StatusOK, append([]byte(xml.Header), b...))
+}
+
+// handleRobotsTxt renders robots.txt that points crawlers to the sitemap.
+func handleRobotsTxt(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	return c.String(http.StatusOK, fmt.Sprintf(`User-agent: *
+Disallow: /admin/
+Disallow: /api/
+Disallow: /report/
+Disallow: /embed/
+
+Sitemap: %s/sitemap.xml
+`, app.consts.RootURL))
+}
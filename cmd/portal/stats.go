@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// handleStatsPage renders the directory statistics page.
+func handleStatsPage(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	st, err := getStats(c)
+	if err != nil {
+		return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching stats.")
+	}
+
+	out := struct {
+		Page
+		Stats             models.Stats
+		DisplayCurrencies []string
+		In                string
+
+		// Largest number of new listings in a month for scaling the growth chart.
+		MaxGrowth int
+
+		// Last time the stats were refreshed.
+		UpdatedAt time.Time
+	}{Stats: st, DisplayCurrencies: app.consts.DisplayCurrencies, In: st.Currency}
+
+	if r, err := app.core.GetViewsRefreshed(); err == nil {
+		out.UpdatedAt = r["directory_stats"]
+	}
+
+	for _, g := range st.Growth {
+		out.MaxGrowth = max(out.MaxGrowth, g.New)
+	}
+
+	out.Title = "Directory statistics"
+	out.Heading = "Statistics"
+	out.Description = "Statistics of free and open source projects and their funding needs in the directory"
+	out.URL = app.consts.RootURL + "/stats"
+
+	return c.Render(http.StatusOK, "stats", out)
+}
+
+// handleGetStats returns the directory statistics.
+func handleGetStats(c echo.Context) error {
+	st, err := getStats(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error fetching stats.")
+	}
+
+	return c.JSON(http.StatusOK, okResp{st})
+}
+
+// getStats returns the directory stats with amounts in the currency
+// requested in the ?in= query param, or the default display currency.
+func getStats(c echo.Context) (models.Stats, error) {
+	var (
+		app = c.Get("app").(*App)
+		in  = strings.ToUpper(c.QueryParam("in"))
+	)
+
+	if !reCurrency.MatchString(in) {
+		in = app.consts.DisplayCurrencies[0]
+	}
+
+	return app.core.GetStats(in)
+}
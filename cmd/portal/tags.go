@@ -0,0 +1,295 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/floss-fund/portal/internal/core"
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/knadh/paginator/v2"
+	"github.com/labstack/echo/v4"
+)
+
+// Number of related tags shown on tag and license pages.
+const numRelatedTags = 20
+
+// termLink is a tag or a license with the number of projects using it.
+type termLink struct {
+	Label string
+	Name  string
+	Count int
+	URL   string
+}
+
+// handleTagsPage lists all canonical tags by the number of projects.
+func handleTagsPage(c echo.Context) error {
+	var (
+		app = c.Get("app").(*App)
+		pg  = app.pg.NewFromURL(c.Request().URL.Query())
+	)
+
+	res, err := app.core.GetTagCounts(pg.Offset, pg.Limit)
+	if err != nil {
+		return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching tags.")
+	}
+
+	terms := make([]termLink, 0, len(res))
+	for _, t := range res {
+		terms = append(terms, termLink{Label: t.Value, Count: t.Count, URL: tagURL(t.Value)})
+	}
+
+	return renderTermsPage(c, "tags", res, terms, pg)
+}
+
+// handleLicensesPage lists all licenses by the number of projects.
+func handleLicensesPage(c echo.Context) error {
+	var (
+		app = c.Get("app").(*App)
+		pg  = app.pg.NewFromURL(c.Request().URL.Query())
+	)
+
+	res, err := app.core.GetLicenseCounts(pg.Offset, pg.Limit)
+	if err != nil {
+		return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching licenses.")
+	}
+
+	terms := make([]termLink, 0, len(res))
+	for _, l := range res {
+		t := termLink{Label: l.Value, Count: l.Count, URL: "/search?type=project&license=" + url.QueryEscape(l.Value)}
+
+		// SPDX licenses have their own pages.
+		if id, ok := strings.CutPrefix(l.Value, "spdx:"); ok {
+			t.Label = id
+			t.Name = app.licenses[id]
+			t.URL = "/licenses/" + url.PathEscape(id)
+		}
+		terms = append(terms, t)
+	}
+
+	return renderTermsPage(c, "licenses", res, terms, pg)
+}
+
+// handleTagPage lists the projects with a tag (and its child tags) along with related tags.
+func handleTagPage(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	tag, err := url.PathUnescape(c.Param("tag"))
+	if err != nil {
+		return errPage(c, http.StatusBadRequest, "", "Error", "Invalid tag.")
+	}
+
+	// Redirect variants and aliases (eg: Go-Lang, golang) to the canonical tag page.
+	canon, err := app.core.GetCanonicalTag(tag)
+	if err != nil {
+		return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching tag.")
+	}
+	if canon == "" {
+		return errPage(c, http.StatusNotFound, "", "Not found", "Tag not found.")
+	}
+	if canon != tag {
+		u := tagURL(canon)
+		if q := c.QueryString(); q != "" {
+			u += "?" + q
+		}
+		return c.Redirect(http.StatusMovedPermanently, u)
+	}
+
+	return renderTermPage(c, "tag", tag, "", []string{tag}, nil)
+}
+
+// handleLicensePage lists the projects with an SPDX license along with related tags.
+func handleLicensePage(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	id, err := url.PathUnescape(c.Param("id"))
+	if err != nil {
+		return errPage(c, http.StatusBadRequest, "", "Error", "Invalid license.")
+	}
+
+	name, ok := app.licenses[id]
+	if !ok {
+		return errPage(c, http.StatusNotFound, "", "Not found", "Unknown SPDX license.")
+	}
+
+	return renderTermPage(c, "license", id, name, nil, []string{"spdx:" + id})
+}
+
+// renderTermsPage renders the paginated listing of tags or licenses.
+func renderTermsPage(c echo.Context, typ string, res []models.TermCount, terms []termLink, pg paginator.Set) error {
+	total := 0
+	if len(res) > 0 {
+		total = res[0].Total
+	}
+	pg.SetTotal(total)
+
+	out := struct {
+		Page
+		Pagination template.HTML
+		Terms      []termLink
+		Total      int
+		Type       string
+	}{}
+	out.Pagination = template.HTML(pg.HTML("", nil))
+	out.Terms = terms
+	out.Total = total
+	out.Type = typ
+	out.Title = fmt.Sprintf("Browse %s - Page %d", typ, pg.Page)
+	out.Heading = fmt.Sprintf("Browse %s (%d)", typ, total)
+	out.Tabs = selectTab(typ)
+
+	return c.Render(http.StatusOK, "terms", out)
+}
+
+// renderTermPage renders the paginated list of projects with a tag or license and the tags related to it.
+func renderTermPage(c echo.Context, typ, term, name string, tags, licenses []string) error {
+	var (
+		app = c.Get("app").(*App)
+		pg  = app.pg.NewFromURL(c.Request().URL.Query())
+	)
+
+	res, err := app.core.SearchProjects(core.SearchFilter{Tags: tags, Licenses: licenses}, "", "", pg.Offset, pg.Limit)
+	if err != nil {
+		return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching results.")
+	}
+
+	total := 0
+	if len(res) > 0 {
+		total = res[0].Total
+	}
+	if total == 0 && pg.Page <= 1 {
+		return errPage(c, http.StatusNotFound, "", "Not found", fmt.Sprintf("There are no projects with the %s %s.", typ, term))
+	}
+	pg.SetTotal(total)
+
+	rel, err := app.core.GetRelatedTags(tags, licenses, numRelatedTags)
+	if err != nil {
+		return errPage(c, http.StatusInternalServerError, "", "Error", "Error fetching results.")
+	}
+
+	related := make([]termLink, 0, len(rel))
+	for _, t := range rel {
+		related = append(related, termLink{Label: t.Value, Count: t.Count, URL: tagURL(t.Value)})
+	}
+
+	out := struct {
+		Page
+		Pagination template.HTML
+		Results    []models.Project
+		Related    []termLink
+		Total      int
+		Type       string
+		Term       string
+		Name       string
+	}{}
+	out.Pagination = template.HTML(pg.HTML("", nil))
+	out.Results = res
+	out.Related = related
+	out.Total = total
+	out.Type = typ
+	out.Term = term
+	out.Name = name
+
+	label := term
+	if name != "" {
+		label = name + " (" + term + ")"
+	}
+	out.Title = fmt.Sprintf("Projects with the %s %s - Page %d", typ, label, pg.Page)
+	out.Description = fmt.Sprintf("%d FOSS projects with the %s %s seeking funding.", total, typ, label)
+	out.Heading = fmt.Sprintf("%s (%d)", label, total)
+	out.Tabs = selectTab(typ + "s")
+
+	return c.Render(http.StatusOK, "term", out)
+}
+
+// tagURL returns the URL of a tag's page.
+func tagURL(tag string) string {
+	return "/tags/" + url.PathEscape(tag)
+}
+
+// handleGetTagAliases returns all tag aliases.
+func handleGetTagAliases(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	out, err := app.core.GetTagAliases()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error fetching tag aliases.")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleUpsertTagAlias maps an alias (eg: golang) to a canonical tag (eg: go).
+func handleUpsertTagAlias(c echo.Context) error {
+	var (
+		app   = c.Get("app").(*App)
+		alias = c.Param("alias")
+		tag   = c.FormValue("tag")
+	)
+
+	out, err := app.core.UpsertTagAlias(alias, tag)
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidTag) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid alias or tag.")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error saving tag alias.")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleDeleteTagAlias deletes a tag alias.
+func handleDeleteTagAlias(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	if err := app.core.DeleteTagAlias(c.Param("alias")); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error deleting tag alias.")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// handleGetTagTaxonomy returns all tags in the tag taxonomy.
+func handleGetTagTaxonomy(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	out, err := app.core.GetTagTaxonomy()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error fetching tag taxonomy.")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleUpsertTagTaxonomy adds a tag to the taxonomy under an optional parent tag.
+func handleUpsertTagTaxonomy(c echo.Context) error {
+	var (
+		app    = c.Get("app").(*App)
+		tag    = c.Param("tag")
+		parent = c.FormValue("parent")
+	)
+
+	out, err := app.core.UpsertTagTaxonomy(tag, parent)
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidTag) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid tag or parent. The parent can't be the tag itself or one of its children.")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error saving tag taxonomy.")
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleDeleteTagTaxonomy deletes a tag from the taxonomy.
+func handleDeleteTagTaxonomy(c echo.Context) error {
+	var app = c.Get("app").(*App)
+
+	if err := app.core.DeleteTagTaxonomy(c.Param("tag")); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error deleting tag from taxonomy.")
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
+	"github.com/floss-fund/portal/internal/migrations"
 	"github.com/jmoiron/sqlx"
 	"github.com/knadh/koanf/v2"
 	"github.com/knadh/stuffbin"
@@ -23,7 +25,10 @@ type migFunc struct {
 // migrations is the list of available migrations ordered by the semver.
 // Each migration is a Go file in internal/migrations named after the semver.
 // The functions are named as: v0.7.0 => migrations.V0_7_0() and are idempotent.
-var migrationsList = []migFunc{{"v1.0.0", nil}}
+var migrationsList = []migFunc{
+	{"v1.0.0", nil},
+	{"v1.1.0", migrations.V1_1_0},
+}
 
 // upgrade upgrades the database to the current version by running SQL migration files
 // for all version from the last known version to the current one.
@@ -41,7 +46,7 @@ func upgrade(db *sqlx.DB, fs stuffbin.FileSystem, prompt bool) {
 		}
 	}
 
-	_, toRun, err := getPendingMigrations(db)
+	_, toRun, err := getPendingMigrations(context.Background(), db)
 	if err != nil {
 		lo.Fatalf("error checking migrations: %v", err)
 	}
@@ -77,7 +82,7 @@ func upgrade(db *sqlx.DB, fs stuffbin.FileSystem, prompt bool) {
 // checkUpgrade checks if the current database schema matches the expected
 // binary version.
 func checkUpgrade(db *sqlx.DB) {
-	lastVer, toRun, err := getPendingMigrations(db)
+	lastVer, toRun, err := getPendingMigrations(context.Background(), db)
 	if err != nil {
 		lo.Fatalf("error checking migrations: %v", err)
 	}
@@ -98,8 +103,8 @@ func checkUpgrade(db *sqlx.DB) {
 
 // getPendingMigrations gets the pending migrations by comparing the last
 // recorded migration in the DB against all migrations listed in `migrations`.
-func getPendingMigrations(db *sqlx.DB) (string, []migFunc, error) {
-	lastVer, err := getLastMigrationVersion(db)
+func getPendingMigrations(ctx context.Context, db *sqlx.DB) (string, []migFunc, error) {
+	lastVer, err := getLastMigrationVersion(ctx, db)
 	if err != nil {
 		return "", nil, err
 	}
@@ -119,9 +124,9 @@ func getPendingMigrations(db *sqlx.DB) (string, []migFunc, error) {
 
 // getLastMigrationVersion returns the last migration semver recorded in the DB.
 // If there isn't any, `v0.0.0` is returned.
-func getLastMigrationVersion(db *sqlx.DB) (string, error) {
+func getLastMigrationVersion(ctx context.Context, db *sqlx.DB) (string, error) {
 	var v string
-	if err := db.Get(&v, `
+	if err := db.GetContext(ctx, &v, `
 		SELECT COALESCE(
 			(SELECT value->>-1 FROM settings WHERE key='migrations'),
 		'v0.0.0')`); err != nil {
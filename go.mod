@@ -6,6 +6,7 @@ toolchain go1.24.1
 
 require (
 	github.com/Masterminds/sprig v2.22.0+incompatible
+	github.com/VictoriaMetrics/metrics v1.35.1
 	github.com/altcha-org/altcha-lib-go v0.2.2
 	github.com/floss-fund/go-funding-json v0.6.0
 	github.com/jmoiron/sqlx v1.4.0
@@ -21,6 +22,7 @@ require (
 	github.com/spf13/pflag v1.0.7
 	github.com/stretchr/testify v1.10.0
 	github.com/zerodha/easyjson v1.0.1
+	golang.org/x/image v0.30.0
 	golang.org/x/mod v0.27.0
 	gopkg.in/volatiletech/null.v6 v6.0.0-20170828023728-0bef4e07ae1b
 )
@@ -44,7 +46,9 @@ require (
 	github.com/pelletier/go-toml v1.9.5 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fastrand v1.1.0 // indirect
 	github.com/valyala/fasttemplate v1.2.2 // indirect
+	github.com/valyala/histogram v1.2.0 // indirect
 	golang.org/x/crypto v0.41.0 // indirect
 	golang.org/x/net v0.43.0 // indirect
 	golang.org/x/sys v0.35.0 // indirect
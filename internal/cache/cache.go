@@ -0,0 +1,159 @@
+// Package cache is a size-bounded, in-memory LRU cache whose items expire
+// after a TTL. Items can be tagged with IDs (eg: manifest IDs) to invalidate
+// all the items that contain a particular ID.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type Opt struct {
+	// Maximum number of items in the cache. The least recently used items
+	// are evicted to make room for new ones.
+	Size int `json:"size"`
+
+	// Time after which an item expires.
+	TTL time.Duration `json:"ttl"`
+}
+
+// Stats are the counters of cache lookups.
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Items     int    `json:"items"`
+}
+
+type item struct {
+	key     string
+	val     any
+	tags    []int
+	expires time.Time
+}
+
+type Cache struct {
+	opt Opt
+
+	// Keys => elements in the LRU list, most recently used first.
+	items map[string]*list.Element
+	lru   *list.List
+
+	// Tag => keys of the items that are tagged with it.
+	tags map[int]map[string]struct{}
+
+	stats Stats
+	mu    sync.Mutex
+}
+
+func New(o Opt) *Cache {
+	return &Cache{
+		opt:   o,
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+		tags:  make(map[int]map[string]struct{}),
+	}
+}
+
+// Get returns the value of an item that hasn't expired.
+func (c *Cache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	it := el.Value.(*item)
+	if time.Now().After(it.expires) {
+		c.remove(el)
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	c.stats.Hits++
+	return it.val, true
+}
+
+// Set adds or replaces an item, tagged with the given IDs, evicting the
+// least recently used item if the cache is full.
+func (c *Cache) Set(key string, val any, tags ...int) {
+	if c.opt.Size < 1 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+
+	for len(c.items) >= c.opt.Size {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+
+	c.items[key] = c.lru.PushFront(&item{
+		key:     key,
+		val:     val,
+		tags:    tags,
+		expires: time.Now().Add(c.opt.TTL),
+	})
+	for _, t := range tags {
+		if c.tags[t] == nil {
+			c.tags[t] = make(map[string]struct{})
+		}
+		c.tags[t][key] = struct{}{}
+	}
+}
+
+// Invalidate removes all the items that are tagged with the given ID.
+func (c *Cache) Invalidate(tag int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.tags[tag] {
+		if el, ok := c.items[key]; ok {
+			c.remove(el)
+		}
+	}
+	delete(c.tags, tag)
+}
+
+// Purge removes all the items.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*list.Element)
+	c.lru.Init()
+	c.tags = make(map[int]map[string]struct{})
+}
+
+// Stats returns the lookup counters and the number of items in the cache.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stats
+	s.Items = len(c.items)
+	return s
+}
+
+// remove removes an item and its tags. The lock should be held.
+func (c *Cache) remove(el *list.Element) {
+	it := c.lru.Remove(el).(*item)
+	delete(c.items, it.key)
+
+	for _, t := range it.tags {
+		delete(c.tags[t], it.key)
+		if len(c.tags[t]) == 0 {
+			delete(c.tags, t)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	c := New(Opt{Size: 2, TTL: time.Hour})
+
+	c.Set("a", 1, 10)
+	c.Set("b", 2, 10, 20)
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	// "b" is the least recently used and is evicted.
+	c.Set("c", 3, 20)
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+
+	// Invalidating a tag removes the items tagged with it.
+	c.Invalidate(10)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+
+	assert.Equal(t, Stats{Hits: 2, Misses: 2, Evictions: 1, Items: 1}, c.Stats())
+
+	c.Purge()
+	assert.Equal(t, 0, c.Stats().Items)
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c := New(Opt{Size: 10, TTL: time.Millisecond})
+
+	c.Set("a", 1)
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Stats().Items)
+}
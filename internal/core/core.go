@@ -1,7 +1,9 @@
 package core
 
 import (
+	"context"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,20 +11,39 @@ import (
 	"net/url"
 	"path"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/floss-fund/go-funding-json/common"
 	v1 "github.com/floss-fund/go-funding-json/schemas/v1"
+	"github.com/floss-fund/portal/internal/cache"
 	"github.com/floss-fund/portal/internal/models"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
+	"gopkg.in/volatiletech/null.v6"
 )
 
 const maxURLLen = 200
 
+// Listings with fewer items than this are counted instead of using the estimated total.
+const minEstimatedTotal = 10000
+
+// Cache tag of cached lists. Manifest IDs, which the other cached reads
+// are tagged with, start at 1.
+const cacheTagLists = 0
+
 var reGithub = regexp.MustCompile(`^(https://github\.com/([^/]+))/([^/]+)/(blob|raw)/([^/]+)`)
 
 type Opt struct {
+	// Postgres text search configurations (languages) that manifests are searched in.
+	// The first one is the default when a manifest's language can't be detected.
+	SearchLanguages []string
+
+	// Cache for the reads behind public pages. nil disables caching.
+	Cache *cache.Cache
 }
 
 const (
@@ -33,55 +54,289 @@ const (
 	ManifestStatusBlocked  = "blocked"
 )
 
+// Facets (filterable fields) whose result counts are computed for searches.
+const (
+	FacetTags         = "tags"
+	FacetLicenses     = "licenses"
+	FacetEntityTypes  = "entity_types"
+	FacetEntityRoles  = "entity_roles"
+	FacetChannelTypes = "channel_types"
+)
+
+// Fields that entity search results (besides the search rank) and listings can be sorted by.
+// Plans and funding are only for listings.
+const (
+	EntityOrderName     = "name"
+	EntityOrderCreated  = "created_at"
+	EntityOrderUpdated  = "updated_at"
+	EntityOrderProjects = "projects"
+	EntityOrderPlans    = "plans"
+	EntityOrderFunding  = "funding"
+)
+
+// Fields that project listings can be sorted by.
+const (
+	ProjectOrderCreated = "created_at"
+	ProjectOrderUpdated = "updated_at"
+	ProjectOrderName    = "name"
+	ProjectOrderEntity  = "entity"
+	ProjectOrderLicense = "license"
+	ProjectOrderCrawled = "crawled_at"
+)
+
+// ListQuery represents the filters, sort order, and position of a page of a listing.
+// At most one of After and Before is set. Neither is the first page.
+type ListQuery struct {
+	Channels []string
+	OrderBy  string
+	Order    string
+	After    *Cursor
+	Before   *Cursor
+	Limit    int
+}
+
+// Cursor is a position in a sorted listing, the sort key (as text) and the ID of an item,
+// and the sort order (field:direction) that the key is of.
+type Cursor struct {
+	Key   null.String `json:"k"`
+	ID    int         `json:"i"`
+	Order string      `json:"o"`
+}
+
+// ParseCursor decodes an opaque cursor returned by Cursor.String().
+func ParseCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var out Cursor
+	if err := json.Unmarshal(b, &out); err != nil || out.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &out, nil
+}
+
+// String encodes the cursor into an opaque URL safe string.
+func (c Cursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// PageCursors are the encoded cursors to the pages after and before a page of a listing.
+// They're empty if there are no such pages.
+type PageCursors struct {
+	Next string `json:"next"`
+	Prev string `json:"prev"`
+}
+
+// listField is a field that a listing can be sorted by, its SQL expression and type.
+type listField struct {
+	expr string
+	typ  string
+}
+
+// SearchFilter represents the query and filters of a project or entity search.
+// Empty values are ignored. Tags, licenses, and plans apply only to projects.
+type SearchFilter struct {
+	Query       string
+	Tags        []string
+	Licenses    []string
+	Plan        PlanFilter
+	Channels    []string
+	EntityTypes []string
+	EntityRoles []string
+}
+
+// facet is a field that search results are counted by. clear() removes the
+// facet's own filter from a search so that the counts of all its values are returned.
+type facet struct {
+	field string
+	join  string
+	clear func(f *SearchFilter)
+}
+
+var (
+	// ProjectFacets is the ordered list of facets for project searches.
+	ProjectFacets = []string{FacetTags, FacetLicenses, FacetEntityTypes, FacetEntityRoles, FacetChannelTypes}
+
+	// EntityFacets is the ordered list of facets for entity searches.
+	EntityFacets = []string{FacetEntityTypes, FacetEntityRoles, FacetChannelTypes}
+
+	projectFacets = map[string]facet{
+		FacetTags:         {"tag", "CROSS JOIN UNNEST(p.tags_canonical) AS tag", func(f *SearchFilter) { f.Tags = nil }},
+		FacetLicenses:     {"license", "CROSS JOIN UNNEST(p.licenses) AS license", func(f *SearchFilter) { f.Licenses = nil }},
+		FacetEntityTypes:  {"e.type", "JOIN entities e ON e.manifest_id = p.manifest_id", func(f *SearchFilter) { f.EntityTypes = nil }},
+		FacetEntityRoles:  {"e.role", "JOIN entities e ON e.manifest_id = p.manifest_id", func(f *SearchFilter) { f.EntityRoles = nil }},
+		FacetChannelTypes: {"fc.type", "JOIN funding_channels fc ON fc.manifest_id = p.manifest_id", func(f *SearchFilter) { f.Channels = nil }},
+	}
+
+	entityFacets = map[string]facet{
+		FacetEntityTypes:  {"e.type", "", func(f *SearchFilter) { f.EntityTypes = nil }},
+		FacetEntityRoles:  {"e.role", "", func(f *SearchFilter) { f.EntityRoles = nil }},
+		FacetChannelTypes: {"fc.type", "JOIN funding_channels fc ON fc.manifest_id = e.manifest_id", func(f *SearchFilter) { f.Channels = nil }},
+	}
+)
+
+// PlanFilter filters projects by the funding plans of their manifests.
+// Zero values are ignored.
+type PlanFilter struct {
+	MinAmount   float64  `json:"min_amount"`
+	MaxAmount   float64  `json:"max_amount"`
+	Currency    string   `json:"currency"`
+	Frequencies []string `json:"frequencies"`
+	Status      string   `json:"status"`
+
+	// If set, plan amounts are converted to this currency before they're
+	// compared with MinAmount and MaxAmount and sorted.
+	AmountCurrency string `json:"amount_currency"`
+}
+
+// IsEmpty returns true if none of the filters are set.
+func (f PlanFilter) IsEmpty() bool {
+	return f.MinAmount == 0 && f.MaxAmount == 0 && f.Currency == "" && len(f.Frequencies) == 0 && f.Status == ""
+}
+
 // Queries contains prepared DB queries.
 type Queries struct {
-	UpsertManifest        *sqlx.Stmt `query:"upsert-manifest"`
-	GetManifests          *sqlx.Stmt `query:"get-manifests"`
-	GetManifestStatus     *sqlx.Stmt `query:"get-manifest-status"`
-	GetForCrawling        *sqlx.Stmt `query:"get-for-crawling"`
-	UpdateManifestStatus  *sqlx.Stmt `query:"update-manifest-status"`
-	UpdateManifestDate    *sqlx.Stmt `query:"update-manifest-date"`
-	UpdateCrawlError      *sqlx.Stmt `query:"update-crawl-error"`
-	DeleteManifest        *sqlx.Stmt `query:"delete-manifest"`
-	GetTopTags            *sqlx.Stmt `query:"get-top-tags"`
-	InsertReport          *sqlx.Stmt `query:"insert-report"`
-	GetRecentProjects     string     `query:"get-recent-projects-snippet"`
-	GetProjects           string     `query:"get-projects-snippet"`
-	GetProjectsByManifest string     `query:"get-projects-by-manifest-snippet"`
-	GetEntities           string     `query:"get-entities"`
-	GetEntityByManifest   string     `query:"get-entity-by-manifest-snippet"`
-	GetManifestsDump      *sqlx.Stmt `query:"get-manifests-dump"`
-	SearchEntities        *sqlx.Stmt `query:"search-entities"`
-	QueryProjectsTpl      string     `query:"query-projects-template"`
-	SearchProjects        string     `query:"search-projects-snippet"`
+	UpsertManifest         *sqlx.Stmt `query:"upsert-manifest"`
+	GetManifests           *sqlx.Stmt `query:"get-manifests"`
+	GetManifestStatus      *sqlx.Stmt `query:"get-manifest-status"`
+	CountManifests         *sqlx.Stmt `query:"count-manifests"`
+	CountManifestsByStatus *sqlx.Stmt `query:"count-manifests-by-status"`
+	GetSitemapManifests    *sqlx.Stmt `query:"get-sitemap-manifests"`
+	GetSitemapProjects     *sqlx.Stmt `query:"get-sitemap-projects"`
+	GetMaxProjectID        *sqlx.Stmt `query:"get-max-project-id"`
+	GetForCrawling         *sqlx.Stmt `query:"get-for-crawling"`
+	UpdateManifestStatus   *sqlx.Stmt `query:"update-manifest-status"`
+	UpdateManifestLang     *sqlx.Stmt `query:"update-manifest-language"`
+	UpdateManifestDate     *sqlx.Stmt `query:"update-manifest-date"`
+	UpdateCrawlError       *sqlx.Stmt `query:"update-crawl-error"`
+	DeleteManifest         *sqlx.Stmt `query:"delete-manifest"`
+	GetTopTags             *sqlx.Stmt `query:"get-top-tags"`
+	InsertReport           *sqlx.Stmt `query:"insert-report"`
+	GetRecentProjects      string     `query:"get-recent-projects-snippet"`
+	GetFeedProjects        string     `query:"get-feed-projects-snippet"`
+	GetProjectsPage        string     `query:"get-projects-page"`
+	CountProjects          *sqlx.Stmt `query:"count-projects"`
+	GetProjectsByIDs       string     `query:"get-projects-by-ids-snippet"`
+	GetProjectsByManifests string     `query:"get-projects-by-manifests-snippet"`
+	GetEntitiesPage        string     `query:"get-entities-page"`
+	CountEntities          *sqlx.Stmt `query:"count-entities"`
+	GetEstimatedTotal      *sqlx.Stmt `query:"get-estimated-total"`
+	GetEntitiesByManifests *sqlx.Stmt `query:"get-entities-by-manifests"`
+	GetManifestsDump       *sqlx.Stmt `query:"get-manifests-dump"`
+	SearchEntitiesFilter   string     `query:"search-entities-filter"`
+	SearchEntities         string     `query:"search-entities"`
+	GetEntityFacets        string     `query:"get-entity-facets"`
+	SearchAll              string     `query:"search-all-snippet"`
+	GetEntitiesByIDs       *sqlx.Stmt `query:"get-entities-by-ids"`
+	QueryProjectsTpl       string     `query:"query-projects-template"`
+	SearchProjectsFilter   string     `query:"search-projects-filter"`
+	SearchProjects         string     `query:"search-projects-snippet"`
+	GetProjectFacets       string     `query:"get-project-facets"`
+	GetExchangeRates       *sqlx.Stmt `query:"get-exchange-rates"`
+	ReplaceExchangeRates   *sqlx.Stmt `query:"replace-exchange-rates"`
+	GetDirectoryStats      *sqlx.Stmt `query:"get-directory-stats"`
+	RefreshView            string     `query:"refresh-view"`
+	UpdateViewRefreshed    *sqlx.Stmt `query:"update-view-refreshed"`
+	GetViewsRefreshed      *sqlx.Stmt `query:"get-views-refreshed"`
+	GetTagAliases          *sqlx.Stmt `query:"get-tag-aliases"`
+	UpsertTagAlias         *sqlx.Stmt `query:"upsert-tag-alias"`
+	DeleteTagAlias         *sqlx.Stmt `query:"delete-tag-alias"`
+	GetTagTaxonomy         *sqlx.Stmt `query:"get-tag-taxonomy"`
+	UpsertTagTaxonomy      *sqlx.Stmt `query:"upsert-tag-taxonomy"`
+	DeleteTagTaxonomy      *sqlx.Stmt `query:"delete-tag-taxonomy"`
+	UpdateCanonicalTags    *sqlx.Stmt `query:"update-canonical-tags"`
+	GetCanonicalTag        *sqlx.Stmt `query:"get-canonical-tag"`
+	GetTagCounts           *sqlx.Stmt `query:"get-tag-counts"`
+	GetLicenseCounts       *sqlx.Stmt `query:"get-license-counts"`
+	GetRelatedTags         *sqlx.Stmt `query:"get-related-tags"`
+	GetSearchSuggestion    *sqlx.Stmt `query:"get-search-suggestion"`
+	Autocomplete           *sqlx.Stmt `query:"autocomplete"`
 }
 
 type Core struct {
 	q   *Queries
 	db  *sqlx.DB
+	opt Opt
 	log *log.Logger
 }
 
 var (
-	ErrNotFound = errors.New("not found")
+	ErrNotFound        = errors.New("not found")
+	ErrInvalidTag      = errors.New("invalid tag")
+	ErrInvalidLanguage = errors.New("invalid language")
+	ErrInvalidCursor   = errors.New("invalid cursor")
+
+	// entityOrderFields maps the fields that entity search results can be sorted by to their columns.
+	entityOrderFields = map[string]string{
+		EntityOrderName:     "LOWER(e.name)",
+		EntityOrderCreated:  "e.created_at",
+		EntityOrderUpdated:  "e.updated_at",
+		EntityOrderProjects: "num_projects",
+	}
+
+	// entityListOrderFields and projectListOrderFields map the fields that entity and project
+	// listings can be sorted by to their columns and types.
+	entityListOrderFields = map[string]listField{
+		EntityOrderCreated:  {"e.created_at", "TIMESTAMPTZ"},
+		EntityOrderUpdated:  {"e.updated_at", "TIMESTAMPTZ"},
+		EntityOrderName:     {"LOWER(e.name)", "TEXT"},
+		EntityOrderProjects: {"es.num_projects", "BIGINT"},
+		EntityOrderPlans:    {"es.num_plans", "BIGINT"},
+		EntityOrderFunding:  {"es.monthly_funding", "NUMERIC"},
+	}
+	projectListOrderFields = map[string]listField{
+		ProjectOrderCreated: {"p.created_at", "TIMESTAMPTZ"},
+		ProjectOrderUpdated: {"p.updated_at", "TIMESTAMPTZ"},
+		ProjectOrderName:    {"p.name", "TEXT"},
+		ProjectOrderEntity:  {"LOWER(e.name)", "TEXT"},
+		ProjectOrderLicense: {"p.licenses[1]", "TEXT"},
+		ProjectOrderCrawled: {"m.updated_at", "TIMESTAMPTZ"},
+	}
+
+	// entityFilterParams and allEntityFilterParams replace the named params in search-entities-filter
+	// with the params of the entity search and facet queries and of search-all-snippet.
+	entityFilterParams    = strings.NewReplacer("%term%", "$1", "%channels%", "$2", "%entity-types%", "$3", "%entity-roles%", "$4")
+	allEntityFilterParams = strings.NewReplacer("%term%", "$1", "%channels%", "$10", "%entity-types%", "$12", "%entity-roles%", "$13")
+
+	// likeEscaper escapes the wildcards in a string for use in a LIKE pattern.
+	likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+	// MaterializedViews is the list of aggregate views that have to be refreshed periodically.
+	MaterializedViews = []string{"top_tags", "directory_stats", "search_words", "entity_stats"}
 )
 
 func New(q *Queries, db *sqlx.DB, o Opt, lo *log.Logger) *Core {
+	// Search in the configured languages. The names are validated config values
+	// and are interpolated into the raw search queries as an array literal.
+	langs := strings.NewReplacer("%search-langs%", fmt.Sprintf("'{%s}'::REGCONFIG[]", strings.Join(o.SearchLanguages, ",")))
+	for _, s := range []*string{&q.SearchProjectsFilter, &q.SearchProjects, &q.SearchEntitiesFilter, &q.SearchEntities, &q.SearchAll} {
+		*s = langs.Replace(*s)
+	}
+
 	return &Core{
 		q:   q,
 		db:  db,
+		opt: o,
 		log: lo,
 	}
 }
 
 // GetManifest retrieves a particular manifest.
 func (c *Core) GetManifest(id int, guid string, status string) (models.ManifestData, error) {
-	out, err := c.getManifests(id, guid, 0, 1, status)
-	if err != nil || len(out) == 0 {
-		return models.ManifestData{}, ErrNotFound
-	}
+	return cached(c, fmt.Sprintf("manifest:%d:%s:%s", id, guid, status), func() (models.ManifestData, error) {
+		out, err := c.getManifests(id, guid, 0, 1, status)
+		if err != nil || len(out) == 0 {
+			return models.ManifestData{}, ErrNotFound
+		}
 
-	return out[0], nil
+		return out[0], nil
+	}, func(m models.ManifestData) []int {
+		return []int{m.ID}
+	})
 }
 
 // GetManifests retrieves N manifests.
@@ -98,7 +353,9 @@ func (c *Core) GetManifests(lastID, limit int, status string) ([]models.Manifest
 // If one exists, its status is returned.
 func (c *Core) GetManifestStatus(url string) (string, error) {
 	var status string
-	if err := c.q.GetManifestStatus.Get(&status, url); err != nil {
+	if err := timed("get-manifest-status", func() error {
+		return c.q.GetManifestStatus.Get(&status, url)
+	}); err != nil {
 		if err == sql.ErrNoRows {
 			return "", nil
 		}
@@ -110,6 +367,66 @@ func (c *Core) GetManifestStatus(url string) (string, error) {
 	return status, nil
 }
 
+// CountManifests returns the number of manifests with the given status.
+func (c *Core) CountManifests(status string) (int, error) {
+	var num int
+	if err := timed("count-manifests", func() error {
+		return c.q.CountManifests.Get(&num, status)
+	}); err != nil {
+		c.log.Printf("error counting manifests: %v", err)
+		return 0, err
+	}
+
+	return num, nil
+}
+
+// CountManifestsByStatus returns the number of manifests of each status.
+func (c *Core) CountManifestsByStatus() ([]models.Facet, error) {
+	var out []models.Facet
+	if err := timed("count-manifests-by-status", func() error {
+		return c.q.CountManifestsByStatus.Select(&out)
+	}); err != nil {
+		c.log.Printf("error counting manifests by status: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetSitemapManifests retrieves paginated active manifests for generating sitemaps.
+func (c *Core) GetSitemapManifests(offset, limit int) ([]models.SitemapManifest, error) {
+	var out []models.SitemapManifest
+	if err := timed("get-sitemap-manifests", func() error { return c.q.GetSitemapManifests.Select(&out, offset, limit) }); err != nil {
+		c.log.Printf("error fetching sitemap manifests: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetSitemapProjects retrieves the projects of active manifests with IDs in the
+// range (fromID, toID] for generating sitemaps.
+func (c *Core) GetSitemapProjects(fromID, toID int) ([]models.SitemapProject, error) {
+	var out []models.SitemapProject
+	if err := timed("get-sitemap-projects", func() error { return c.q.GetSitemapProjects.Select(&out, fromID, toID) }); err != nil {
+		c.log.Printf("error fetching sitemap projects: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetMaxProjectID returns the highest project ID, or 0 if there are no projects.
+func (c *Core) GetMaxProjectID() (int, error) {
+	var out int
+	if err := timed("get-max-project-id", func() error { return c.q.GetMaxProjectID.Get(&out) }); err != nil {
+		c.log.Printf("error fetching max project ID: %v", err)
+		return 0, err
+	}
+
+	return out, nil
+}
+
 // UpsertManifest upserts an entry into the database.
 func (c *Core) UpsertManifest(m models.ManifestData, status string) error {
 
@@ -126,10 +443,14 @@ func (c *Core) UpsertManifest(m models.ManifestData, status string) error {
 		return err
 	}
 
-	if _, err := c.q.UpsertManifest.Exec(json.RawMessage(b), m.URLStr, m.GUID, json.RawMessage("{}"), status, ""); err != nil {
+	var id int
+	if err := timed("upsert-manifest", func() error {
+		return c.q.UpsertManifest.Get(&id, json.RawMessage(b), m.URLStr, m.GUID, json.RawMessage("{}"), status, "", pq.Array(c.opt.SearchLanguages))
+	}); err != nil {
 		c.log.Printf("error upsering manifest: %v", err)
 		return err
 	}
+	c.invalidate(id)
 
 	return nil
 }
@@ -138,7 +459,9 @@ func (c *Core) UpsertManifest(m models.ManifestData, status string) error {
 // continued from the last processed row ID which is the offsetID.
 func (c *Core) GetManifestForCrawling(age string, offsetID, maxCrawlErrors, limit int) ([]models.ManifestJob, error) {
 	var out []models.ManifestJob
-	if err := c.q.GetForCrawling.Select(&out, offsetID, age, maxCrawlErrors, limit); err != nil {
+	if err := timed("get-for-crawling", func() error {
+		return c.q.GetForCrawling.Select(&out, offsetID, age, maxCrawlErrors, limit)
+	}); err != nil {
 		c.log.Printf("error fetching URLs for crawling: %v", err)
 		return nil, err
 	}
@@ -157,19 +480,49 @@ func (c *Core) GetManifestForCrawling(age string, offsetID, maxCrawlErrors, limi
 	return out, nil
 }
 
+// UpdateManifestLanguage sets the language (text search configuration) that a manifest's
+// entity and projects are searched in. An empty lang resets it to the detected language.
+// The language that's in effect is returned.
+func (c *Core) UpdateManifestLanguage(id int, lang string) (string, error) {
+	if lang != "" && lang != "simple" && !slices.Contains(c.opt.SearchLanguages, lang) {
+		return "", ErrInvalidLanguage
+	}
+
+	var out string
+	if err := timed("update-manifest-language", func() error {
+		return c.q.UpdateManifestLang.Get(&out, id, lang, pq.Array(c.opt.SearchLanguages))
+	}); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+
+		c.log.Printf("error updating manifest language: %d: %v", id, err)
+		return "", err
+	}
+
+	return out, nil
+}
+
 // UpdateManifestStatus updates a manifest's status.
 func (c *Core) UpdateManifestStatus(id int, status string) error {
-	if _, err := c.q.UpdateManifestStatus.Exec(id, status); err != nil {
+	if err := timed("update-manifest-status", func() error {
+		_, err := c.q.UpdateManifestStatus.Exec(id, status)
+		return err
+	}); err != nil {
 		c.log.Printf("error updating manifest status: %d: %v", id, err)
 		return err
 	}
+	c.invalidate(id)
 
 	return nil
 }
 
 // UpdateManifestDate updates a manifest's "updated_at" date.
 func (c *Core) UpdateManifestDate(id int) error {
-	if _, err := c.q.UpdateManifestDate.Exec(id); err != nil {
+	if err := timed("update-manifest-date", func() error {
+		_, err := c.q.UpdateManifestDate.Exec(id)
+		return err
+	}); err != nil {
 		c.log.Printf("error updating manifest date: %d: %v", id, err)
 		return err
 	}
@@ -181,30 +534,47 @@ func (c *Core) UpdateManifestDate(id int) error {
 // it to 'disabled' if it exceeds the given limit.
 func (c *Core) UpdateManifestCrawlError(id int, message string, maxErrors int, disableOnErrors bool) (string, error) {
 	var status string
-	if err := c.q.UpdateCrawlError.Get(&status, id, message, maxErrors, disableOnErrors); err != nil {
+	if err := timed("update-crawl-error", func() error {
+		return c.q.UpdateCrawlError.Get(&status, id, message, maxErrors, disableOnErrors)
+	}); err != nil {
 		c.log.Printf("error updating manifest crawl error status: %d: %v", id, err)
 		return "", err
 	}
 
+	// The manifest may have been disabled.
+	c.invalidate(id)
+
 	return status, nil
 }
 
 // DeleteManifest deletes a manifest and all associated data;
 func (c *Core) DeleteManifest(id int, guid string) error {
-	if _, err := c.q.DeleteManifest.Exec(id, guid); err != nil {
+	var ids []int
+	if err := timed("delete-manifest", func() error {
+		return c.q.DeleteManifest.Select(&ids, id, guid)
+	}); err != nil {
 		c.log.Printf("error deleting manifest: %d: %v", id, err)
 		return err
 	}
+	for _, id := range ids {
+		c.invalidate(id)
+	}
 
 	return nil
 }
 
 // GetTopTags returns top N tags referenced across projects.
 func (c *Core) GetTopTags(limit int) ([]string, error) {
+	return cached(c, fmt.Sprintf("top-tags:%d", limit), func() ([]string, error) {
+		return c.getTopTags(limit)
+	}, listTags)
+}
+
+func (c *Core) getTopTags(limit int) ([]string, error) {
 	res := []struct {
 		Tag string `db:"tag"`
 	}{}
-	if err := c.q.GetTopTags.Select(&res, limit); err != nil {
+	if err := timed("get-top-tags", func() error { return c.q.GetTopTags.Select(&res, limit) }); err != nil {
 		c.log.Printf("error fetching top tags: %v", err)
 		return nil, err
 	}
@@ -219,10 +589,18 @@ func (c *Core) GetTopTags(limit int) ([]string, error) {
 
 // GetRecentProjects retrieves N recently updated projects.
 func (c *Core) GetRecentProjects(limit int) (models.Projects, error) {
+	return cached(c, fmt.Sprintf("recent-projects:%d", limit), func() (models.Projects, error) {
+		return c.getRecentProjects(limit)
+	}, listTags)
+}
+
+func (c *Core) getRecentProjects(limit int) (models.Projects, error) {
 	exp := strings.ReplaceAll(c.q.QueryProjectsTpl, "%query%", c.q.GetRecentProjects)
 
 	var out models.Projects
-	if err := c.db.Select(&out, exp, limit); err != nil {
+	if err := timed("get-recent-projects-snippet", func() error {
+		return c.db.Select(&out, exp, limit)
+	}); err != nil {
 		c.log.Printf("error fetching recent projects: %v", err)
 		return nil, err
 	}
@@ -235,23 +613,22 @@ func (c *Core) GetRecentProjects(limit int) (models.Projects, error) {
 	return out, nil
 }
 
-// InsertManifestReport inserts a flagged report with reason for the manifest
-func (c *Core) InsertManifestReport(id int, reason string) error {
-	if _, err := c.q.InsertReport.Exec(id, reason); err != nil {
-		c.log.Printf("error inserting report for manifest: %d: %v", id, err)
-		return err
-	}
-
-	return nil
+// GetFeedProjects retrieves N projects of active manifests ordered by the given date
+// field (created_at, updated_at), optionally filtered by a tag.
+func (c *Core) GetFeedProjects(orderBy, tag string, limit int) (models.Projects, error) {
+	return cached(c, fmt.Sprintf("feed-projects:%s:%s:%d", orderBy, tag, limit), func() (models.Projects, error) {
+		return c.getFeedProjects(orderBy, tag, limit)
+	}, listTags)
 }
 
-// GetProjects retrieves paginated projects optionally sorted by certain fields.
-func (c *Core) GetProjects(orderBy, order string, offset, limit int) (models.Projects, error) {
-	exp := strings.ReplaceAll(c.q.QueryProjectsTpl, "%query%", fmt.Sprintf(c.q.GetProjects, orderBy+" "+order))
+func (c *Core) getFeedProjects(orderBy, tag string, limit int) (models.Projects, error) {
+	exp := strings.ReplaceAll(c.q.QueryProjectsTpl, "%query%", fmt.Sprintf(c.q.GetFeedProjects, orderBy))
 
 	var out models.Projects
-	if err := c.db.Select(&out, exp, offset, limit); err != nil {
-		c.log.Printf("error fetching projects by start letter: %v", err)
+	if err := timed("get-feed-projects-snippet", func() error {
+		return c.db.Select(&out, exp, tag, limit)
+	}); err != nil {
+		c.log.Printf("error fetching feed projects: %v", err)
 		return nil, err
 	}
 
@@ -263,31 +640,93 @@ func (c *Core) GetProjects(orderBy, order string, offset, limit int) (models.Pro
 	return out, nil
 }
 
-// GetProjects retrieves paginated entities optionally sorted by certain fields.
-func (c *Core) GetEntities(orderBy, order string, offset, limit int) ([]models.Entity, error) {
-	var out []models.Entity
+// InsertManifestReport inserts a flagged report with reason for the manifest
+func (c *Core) InsertManifestReport(id int, reason string) error {
+	if err := timed("insert-report", func() error {
+		_, err := c.q.InsertReport.Exec(id, reason)
+		return err
+	}); err != nil {
+		c.log.Printf("error inserting report for manifest: %d: %v", id, err)
+		return err
+	}
 
-	if err := c.db.Select(&out, fmt.Sprintf(c.q.GetEntities, orderBy+" "+order), offset, limit); err != nil {
-		c.log.Printf("error fetching entities by start letter: %v", err)
-		return nil, err
+	return nil
+}
+
+// GetProjectsPage retrieves a page of projects of active manifests sorted by one of the ProjectOrder*
+// fields (created_at by default), after or before the cursors in the query.
+func (c *Core) GetProjectsPage(q ListQuery) (models.Projects, PageCursors, error) {
+	ids, cur, err := c.getListPage("get-projects-page", c.q.GetProjectsPage, projectListOrderFields, "p.id", q)
+	if err == ErrInvalidCursor {
+		return nil, cur, err
+	} else if err != nil {
+		c.log.Printf("error fetching projects page: %v", err)
+		return nil, cur, err
 	}
 
-	for n, o := range out {
-		if err := o.Parse(); err != nil {
-			c.log.Printf("error parsing entity: %s: %v", o.ManifestGUID, err)
-			return nil, err
-		}
-		out[n] = o
+	out, err := c.getProjectsByIDs(ids)
+	if err != nil {
+		return nil, cur, err
 	}
 
-	return out, nil
+	return out, cur, nil
 }
 
-// SearchEntities searches entities by keywords.
-func (c *Core) SearchEntities(query string, offset, limit int) ([]models.Entity, error) {
-	var out []models.Entity
+// GetEntitiesPage retrieves a page of entities of active manifests sorted by one of the EntityOrder*
+// fields (created_at by default), after or before the cursors in the query. Sorting by the number of
+// projects and plans and the funding uses the periodically refreshed entity_stats.
+func (c *Core) GetEntitiesPage(q ListQuery) ([]models.Entity, PageCursors, error) {
+	ids, cur, err := c.getListPage("get-entities-page", c.q.GetEntitiesPage, entityListOrderFields, "e.id", q)
+	if err == ErrInvalidCursor {
+		return nil, cur, err
+	} else if err != nil {
+		c.log.Printf("error fetching entities page: %v", err)
+		return nil, cur, err
+	}
+
+	out, err := c.getEntitiesByIDs(ids)
+	if err != nil {
+		return nil, cur, err
+	}
 
-	if err := c.q.SearchEntities.Select(&out, query, offset, limit); err != nil {
+	return out, cur, nil
+}
+
+// CountProjects returns the number of projects of active manifests. Without filters, the number is
+// estimated from the periodically refreshed directory stats, which is indicated by the bool.
+func (c *Core) CountProjects(channels []string) (int, bool, error) {
+	return c.countListing("count-projects", c.q.CountProjects, "projects", channels)
+}
+
+// CountEntities returns the number of entities of active manifests. Without filters, the number is
+// estimated from the periodically refreshed directory stats, which is indicated by the bool.
+func (c *Core) CountEntities(channels []string) (int, bool, error) {
+	// Every active manifest has one entity.
+	return c.countListing("count-entities", c.q.CountEntities, "manifests", channels)
+}
+
+// SearchEntities searches the entities of active manifests by keywords, entity types and roles, and channel
+// types, ordered by the search rank or one of the EntityOrder* fields.
+func (c *Core) SearchEntities(f SearchFilter, orderBy, order string, offset, limit int) ([]models.Entity, error) {
+	// Order by the search rank or one of the entity fields.
+	ord := "rank DESC, e.id"
+	if field, ok := entityOrderFields[orderBy]; ok {
+		if order != "ASC" && order != "DESC" {
+			// Names are alphabetical and the rest are the latest or largest first by default.
+			order = "DESC"
+			if orderBy == EntityOrderName {
+				order = "ASC"
+			}
+		}
+		ord = field + " " + order + " NULLS LAST, e.id"
+	}
+
+	exp := strings.NewReplacer("%filter%", entityFilterParams.Replace(c.q.SearchEntitiesFilter), "%order%", ord).Replace(c.q.SearchEntities)
+
+	var out []models.Entity
+	if err := timed("search-entities", func() error {
+		return c.db.Select(&out, exp, append(entityFilterArgs(f), offset, limit)...)
+	}); err != nil {
 		c.log.Printf("error searching entities: %v", err)
 		return nil, err
 	}
@@ -303,12 +742,24 @@ func (c *Core) SearchEntities(query string, offset, limit int) ([]models.Entity,
 	return out, nil
 }
 
-// SearchProjects searches projects by keywords.
-func (c *Core) SearchProjects(query string, tags, licenses []string, orderBy, order string, offset, limit int) (models.Projects, error) {
-	exp := strings.ReplaceAll(c.q.QueryProjectsTpl, "%query%", c.q.SearchProjects)
+// SearchProjects searches projects by keywords, tags, licenses, funding plans, entities, and channel types.
+func (c *Core) SearchProjects(f SearchFilter, orderBy, order string, offset, limit int) (models.Projects, error) {
+	// Order by the search rank or the smallest plan amount.
+	ord := "rank DESC, id DESC"
+	if orderBy == "amount" {
+		if order != "DESC" {
+			order = "ASC"
+		}
+		ord = "amount " + order + " NULLS LAST, id DESC"
+	}
+
+	exp := strings.NewReplacer("%filter%", c.q.SearchProjectsFilter, "%order%", ord).Replace(c.q.SearchProjects)
+	exp = strings.ReplaceAll(c.q.QueryProjectsTpl, "%query%", exp)
 
 	var out models.Projects
-	if err := c.db.Select(&out, exp, query, pq.Array(tags), pq.Array(licenses), offset, limit); err != nil {
+	if err := timed("search-projects-snippet", func() error {
+		return c.db.Select(&out, exp, append(projectFilterArgs(f), offset, limit)...)
+	}); err != nil {
 		c.log.Printf("error searching projects: %v", err)
 		return nil, err
 	}
@@ -322,10 +773,538 @@ func (c *Core) SearchProjects(query string, tags, licenses []string, orderBy, or
 	return out, nil
 }
 
+// SearchAll searches projects and entities and returns them merged by rank, along with the number
+// of each that match. Entities are excluded if there are project only filters (tags, licenses, plans).
+func (c *Core) SearchAll(f SearchFilter, offset, limit int) ([]models.SearchResult, models.SearchCounts, error) {
+	exp := strings.NewReplacer(
+		"%project-filter%", c.q.SearchProjectsFilter,
+		"%entity-filter%", allEntityFilterParams.Replace(c.q.SearchEntitiesFilter),
+	).Replace(c.q.SearchAll)
+
+	withEntities := len(f.Tags) == 0 && len(f.Licenses) == 0 && f.Plan.IsEmpty()
+
+	var res []struct {
+		models.SearchCounts
+		Type null.String `db:"type"`
+		ID   null.Int    `db:"id"`
+	}
+	if err := timed("search-all-snippet", func() error {
+		return c.db.Select(&res, exp, append(projectFilterArgs(f), withEntities, offset, limit)...)
+	}); err != nil {
+		c.log.Printf("error searching projects and entities: %v", err)
+		return nil, models.SearchCounts{}, err
+	}
+
+	var (
+		counts   models.SearchCounts
+		prjIDs   []int
+		entIDs   []int
+		projects = map[int]*models.Project{}
+		entities = map[int]*models.Entity{}
+	)
+	for _, r := range res {
+		counts = r.SearchCounts
+		if !r.ID.Valid {
+			continue
+		}
+
+		if r.Type.String == "project" {
+			prjIDs = append(prjIDs, r.ID.Int)
+		} else {
+			entIDs = append(entIDs, r.ID.Int)
+		}
+	}
+
+	// Fetch the full projects and entities.
+	prj, err := c.getProjectsByIDs(prjIDs)
+	if err != nil {
+		return nil, counts, err
+	}
+	for n := range prj {
+		projects[prj[n].ID] = &prj[n]
+	}
+
+	ent, err := c.getEntitiesByIDs(entIDs)
+	if err != nil {
+		return nil, counts, err
+	}
+	for n := range ent {
+		entities[ent[n].ID] = &ent[n]
+	}
+
+	// Merge them in the order of the search results.
+	out := make([]models.SearchResult, 0, len(res))
+	for _, r := range res {
+		if !r.ID.Valid {
+			continue
+		}
+
+		if r.Type.String == "project" {
+			if p, ok := projects[r.ID.Int]; ok {
+				out = append(out, models.SearchResult{Type: "project", Project: p})
+			}
+		} else if e, ok := entities[r.ID.Int]; ok {
+			out = append(out, models.SearchResult{Type: "entity", Entity: e})
+		}
+	}
+
+	return out, counts, nil
+}
+
+// GetSearchSuggestion returns a "did you mean" suggestion for a search query where
+// the words that don't match anything in the search index are replaced with similar
+// words that do. An empty string is returned if all the words match.
+func (c *Core) GetSearchSuggestion(query string) (string, error) {
+	var out string
+	if err := timed("get-search-suggestion", func() error {
+		return c.q.GetSearchSuggestion.Get(&out, query)
+	}); err != nil {
+		c.log.Printf("error fetching search suggestion: %v", err)
+		return "", err
+	}
+
+	return out, nil
+}
+
+// GetAutocomplete returns the top N project names, entity names, and tags that match
+// a partial search query. The query is cancelled if ctx expires.
+func (c *Core) GetAutocomplete(ctx context.Context, query string, limit int) (models.Autocomplete, error) {
+	out := models.Autocomplete{
+		Projects: []models.AutocompleteItem{},
+		Entities: []models.AutocompleteItem{},
+		Tags:     []models.AutocompleteItem{},
+	}
+
+	query = strings.ToLower(query)
+
+	var res []models.AutocompleteItem
+	if err := timed("autocomplete", func() error {
+		return c.q.Autocomplete.SelectContext(ctx, &res, query, likeEscaper.Replace(query), limit)
+	}); err != nil {
+		c.log.Printf("error fetching autocomplete suggestions: %v", err)
+		return out, err
+	}
+
+	for _, r := range res {
+		switch r.Type {
+		case "project":
+			out.Projects = append(out.Projects, r)
+		case "entity":
+			out.Entities = append(out.Entities, r)
+		case "tag":
+			out.Tags = append(out.Tags, r)
+		}
+	}
+
+	return out, nil
+}
+
+// GetProjectFacets returns the number of projects matching a search for the top N values
+// of each of the given facets (eg: FacetTags). A facet's own filter is ignored when counting
+// its values so that the counts reflect all available choices and not just the selected ones.
+func (c *Core) GetProjectFacets(f SearchFilter, facets []string, limit int) (map[string][]models.Facet, error) {
+	return c.getFacets("get-project-facets", c.q.GetProjectFacets, c.q.SearchProjectsFilter, projectFacets, projectFilterArgs, f, facets, limit)
+}
+
+// GetEntityFacets returns the number of entities matching a search for the top N values
+// of each of the given facets (eg: FacetEntityTypes). See GetProjectFacets.
+func (c *Core) GetEntityFacets(f SearchFilter, facets []string, limit int) (map[string][]models.Facet, error) {
+	return c.getFacets("get-entity-facets", c.q.GetEntityFacets, entityFilterParams.Replace(c.q.SearchEntitiesFilter), entityFacets, entityFilterArgs, f, facets, limit)
+}
+
+func (c *Core) getFacets(queryName, tpl, filter string, defs map[string]facet, argsFn func(SearchFilter) []any,
+	f SearchFilter, facets []string, limit int) (map[string][]models.Facet, error) {
+	out := make(map[string][]models.Facet, len(facets))
+	for _, name := range facets {
+		fc, ok := defs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown facet: %s", name)
+		}
+
+		exp := strings.NewReplacer("%field%", fc.field, "%join%", fc.join, "%filter%", filter).Replace(tpl)
+
+		ff := f
+		fc.clear(&ff)
+
+		res := []models.Facet{}
+		if err := timed(queryName, func() error {
+			return c.db.Select(&res, exp, append(argsFn(ff), limit)...)
+		}); err != nil {
+			c.log.Printf("error fetching %s facets: %v", name, err)
+			return nil, err
+		}
+		out[name] = res
+	}
+
+	return out, nil
+}
+
+// GetExchangeRates retrieves all currency exchange rates.
+func (c *Core) GetExchangeRates() (models.ExchangeRates, error) {
+	var res []struct {
+		Currency string  `db:"currency"`
+		Rate     float64 `db:"rate"`
+	}
+	if err := timed("get-exchange-rates", func() error {
+		return c.q.GetExchangeRates.Select(&res)
+	}); err != nil {
+		c.log.Printf("error fetching exchange rates: %v", err)
+		return nil, err
+	}
+
+	out := make(models.ExchangeRates, len(res))
+	for _, r := range res {
+		out[r.Currency] = r.Rate
+	}
+
+	return out, nil
+}
+
+// ReplaceExchangeRates replaces all existing currency exchange rates with the given rates.
+func (c *Core) ReplaceExchangeRates(rates models.ExchangeRates) error {
+	var (
+		currencies = make([]string, 0, len(rates))
+		vals       = make([]float64, 0, len(rates))
+	)
+	for cur, r := range rates {
+		currencies = append(currencies, cur)
+		vals = append(vals, r)
+	}
+
+	if err := timed("replace-exchange-rates", func() error {
+		_, err := c.q.ReplaceExchangeRates.Exec(pq.Array(currencies), pq.Array(vals))
+		return err
+	}); err != nil {
+		c.log.Printf("error replacing exchange rates: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetStats returns the aggregate statistics of the directory with
+// funding amounts normalised to the given currency.
+func (c *Core) GetStats(currency string) (models.Stats, error) {
+	// Stats are aggregated in a view and change when it or the exchange rates are refreshed.
+	return cached(c, "stats:"+currency, func() (models.Stats, error) {
+		return c.getStats(currency)
+	}, nil)
+}
+
+func (c *Core) getStats(currency string) (models.Stats, error) {
+	var res []struct {
+		Stat     string  `db:"stat"`
+		Key      string  `db:"key"`
+		Currency string  `db:"currency"`
+		Value    float64 `db:"value"`
+	}
+	if err := timed("get-directory-stats", func() error { return c.q.GetDirectoryStats.Select(&res) }); err != nil {
+		c.log.Printf("error fetching stats: %v", err)
+		return models.Stats{}, err
+	}
+
+	rates, err := c.GetExchangeRates()
+	if err != nil {
+		return models.Stats{}, err
+	}
+
+	var (
+		out = models.Stats{
+			Totals:            map[string]int{},
+			ManifestsByStatus: []models.Facet{},
+			EntitiesByType:    []models.Facet{},
+			EntitiesByRole:    []models.Facet{},
+			ProjectsByLicense: []models.Facet{},
+			ProjectsByTag:     []models.Facet{},
+			PlansByFrequency:  []models.Facet{},
+			Currency:          currency,
+			History:           []models.StatHistory{},
+			SkippedCurrencies: []string{},
+			Growth:            []models.StatGrowth{},
+		}
+
+		history = map[string]*models.StatHistory{}
+		skipped = map[string]bool{}
+	)
+
+	// Converts an amount to the stats currency, recording the currencies that can't be.
+	convert := func(amount float64, cur string) (float64, bool) {
+		if !rates.CanConvert(cur, currency) {
+			skipped[cur] = true
+			return 0, false
+		}
+		return rates.Convert(amount, cur, currency), true
+	}
+
+	for _, r := range res {
+		f := models.Facet{Value: r.Key, Count: int(r.Value)}
+
+		switch r.Stat {
+		case "totals":
+			out.Totals[r.Key] = int(r.Value)
+		case "manifests_status":
+			out.ManifestsByStatus = append(out.ManifestsByStatus, f)
+		case "entities_type":
+			out.EntitiesByType = append(out.EntitiesByType, f)
+		case "entities_role":
+			out.EntitiesByRole = append(out.EntitiesByRole, f)
+		case "projects_license":
+			out.ProjectsByLicense = append(out.ProjectsByLicense, f)
+		case "projects_tag":
+			out.ProjectsByTag = append(out.ProjectsByTag, f)
+		case "plans_frequency":
+			out.PlansByFrequency = append(out.PlansByFrequency, f)
+		case "plans_monthly":
+			if v, ok := convert(r.Value, r.Currency); ok {
+				out.MonthlyFunding += v
+			}
+		case "history_income", "history_expenses":
+			v, ok := convert(r.Value, r.Currency)
+			if !ok {
+				continue
+			}
+
+			h, ok := history[r.Key]
+			if !ok {
+				h = &models.StatHistory{Year: r.Key}
+				history[r.Key] = h
+			}
+			if r.Stat == "history_income" {
+				h.Income += v
+			} else {
+				h.Expenses += v
+			}
+		case "listings_month":
+			out.Growth = append(out.Growth, models.StatGrowth{Month: r.Key, New: int(r.Value)})
+		}
+	}
+
+	for _, h := range history {
+		out.History = append(out.History, *h)
+	}
+	sort.Slice(out.History, func(i, j int) bool {
+		return out.History[i].Year < out.History[j].Year
+	})
+
+	for cur := range skipped {
+		out.SkippedCurrencies = append(out.SkippedCurrencies, cur)
+	}
+	sort.Strings(out.SkippedCurrencies)
+
+	// Cumulative number of listings by month.
+	sort.Slice(out.Growth, func(i, j int) bool {
+		return out.Growth[i].Month < out.Growth[j].Month
+	})
+	total := 0
+	for n, g := range out.Growth {
+		total += g.New
+		out.Growth[n].Total = total
+	}
+
+	return out, nil
+}
+
+// RefreshView refreshes a materialized view and records the time of the refresh.
+func (c *Core) RefreshView(name string) error {
+	if !slices.Contains(MaterializedViews, name) {
+		return fmt.Errorf("unknown view: %s", name)
+	}
+
+	if err := timed("refresh-view", func() error {
+		_, err := c.db.Exec(fmt.Sprintf(c.q.RefreshView, pq.QuoteIdentifier(name)))
+		return err
+	}); err != nil {
+		c.log.Printf("error refreshing view: %s: %v", name, err)
+		return err
+	}
+
+	if err := timed("update-view-refreshed", func() error {
+		_, err := c.q.UpdateViewRefreshed.Exec(name)
+		return err
+	}); err != nil {
+		c.log.Printf("error recording view refresh: %s: %v", name, err)
+		return err
+	}
+
+	// Cached reads may have been aggregated from the view.
+	c.purgeCache()
+
+	return nil
+}
+
+// GetViewsRefreshed returns the last refreshed time of materialized views.
+func (c *Core) GetViewsRefreshed() (map[string]time.Time, error) {
+	var b []byte
+	if err := timed("get-views-refreshed", func() error {
+		return c.q.GetViewsRefreshed.Get(&b)
+	}); err != nil {
+		c.log.Printf("error fetching view refresh times: %v", err)
+		return nil, err
+	}
+
+	out := map[string]time.Time{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		c.log.Printf("error unmarshalling view refresh times: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetTagAliases returns all tag aliases.
+func (c *Core) GetTagAliases() ([]models.TagAlias, error) {
+	out := []models.TagAlias{}
+	if err := timed("get-tag-aliases", func() error { return c.q.GetTagAliases.Select(&out) }); err != nil {
+		c.log.Printf("error fetching tag aliases: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// UpsertTagAlias maps an alias to a canonical tag and recomputes the canonical tags of projects.
+// Both are normalised. ErrInvalidTag is returned if either is empty or if they are the same.
+func (c *Core) UpsertTagAlias(alias, tag string) (string, error) {
+	var out string
+	if err := timed("upsert-tag-alias", func() error { return c.q.UpsertTagAlias.Get(&out, alias, tag) }); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrInvalidTag
+		}
+
+		c.log.Printf("error upserting tag alias: %v", err)
+		return "", err
+	}
+
+	return out, c.updateCanonicalTags()
+}
+
+// DeleteTagAlias deletes a tag alias and recomputes the canonical tags of projects.
+func (c *Core) DeleteTagAlias(alias string) error {
+	if err := timed("delete-tag-alias", func() error {
+		_, err := c.q.DeleteTagAlias.Exec(alias)
+		return err
+	}); err != nil {
+		c.log.Printf("error deleting tag alias: %v", err)
+		return err
+	}
+
+	return c.updateCanonicalTags()
+}
+
+// GetTagTaxonomy returns all tags in the tag taxonomy.
+func (c *Core) GetTagTaxonomy() ([]models.TagNode, error) {
+	out := []models.TagNode{}
+	if err := timed("get-tag-taxonomy", func() error { return c.q.GetTagTaxonomy.Select(&out) }); err != nil {
+		c.log.Printf("error fetching tag taxonomy: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// UpsertTagTaxonomy sets the parent of a tag in the taxonomy. An empty parent makes it a top level tag.
+// ErrInvalidTag is returned if the tag is empty or if the parent would create a cycle.
+func (c *Core) UpsertTagTaxonomy(tag, parent string) (string, error) {
+	var out string
+	if err := timed("upsert-tag-taxonomy", func() error {
+		return c.q.UpsertTagTaxonomy.Get(&out, tag, parent)
+	}); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrInvalidTag
+		}
+
+		c.log.Printf("error upserting tag taxonomy: %v", err)
+		return "", err
+	}
+
+	return out, nil
+}
+
+// DeleteTagTaxonomy deletes a tag from the taxonomy. Its children become top level tags.
+func (c *Core) DeleteTagTaxonomy(tag string) error {
+	if err := timed("delete-tag-taxonomy", func() error {
+		_, err := c.q.DeleteTagTaxonomy.Exec(tag)
+		return err
+	}); err != nil {
+		c.log.Printf("error deleting tag from taxonomy: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetCanonicalTag returns the normalised and de-aliased form of a tag.
+func (c *Core) GetCanonicalTag(tag string) (string, error) {
+	var out string
+	if err := timed("get-canonical-tag", func() error {
+		return c.q.GetCanonicalTag.Get(&out, tag)
+	}); err != nil {
+		c.log.Printf("error fetching canonical tag: %v", err)
+		return "", err
+	}
+
+	return out, nil
+}
+
+// GetTagCounts returns canonical tags and the number of active projects using them.
+func (c *Core) GetTagCounts(offset, limit int) ([]models.TermCount, error) {
+	out := []models.TermCount{}
+	if err := timed("get-tag-counts", func() error {
+		return c.q.GetTagCounts.Select(&out, offset, limit)
+	}); err != nil {
+		c.log.Printf("error fetching tag counts: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetLicenseCounts returns licenses and the number of active projects using them.
+func (c *Core) GetLicenseCounts(offset, limit int) ([]models.TermCount, error) {
+	out := []models.TermCount{}
+	if err := timed("get-license-counts", func() error {
+		return c.q.GetLicenseCounts.Select(&out, offset, limit)
+	}); err != nil {
+		c.log.Printf("error fetching license counts: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetRelatedTags returns the tags that most often occur in projects with the given tags or licenses.
+func (c *Core) GetRelatedTags(tags, licenses []string, limit int) ([]models.Facet, error) {
+	out := []models.Facet{}
+	if err := timed("get-related-tags", func() error {
+		return c.q.GetRelatedTags.Select(&out, textArray(tags), textArray(licenses), limit)
+	}); err != nil {
+		c.log.Printf("error fetching related tags: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// updateCanonicalTags recomputes the canonical tags of all projects and the top tags
+// after the tag aliases have changed.
+func (c *Core) updateCanonicalTags() error {
+	if err := timed("update-canonical-tags", func() error {
+		_, err := c.q.UpdateCanonicalTags.Exec()
+		return err
+	}); err != nil {
+		c.log.Printf("error updating canonical tags: %v", err)
+		return err
+	}
+
+	return c.RefreshView("top_tags")
+}
+
 // GetManifestsDump retrieves N manifests raw dumps for export.
 func (c *Core) GetManifestsDump(lastID, limit int) ([]models.ManifestExport, error) {
 	var out []models.ManifestExport
-	if err := c.q.GetManifestsDump.Select(&out, lastID, limit); err != nil {
+	if err := timed("get-manifests-dump", func() error {
+		return c.q.GetManifestsDump.Select(&out, lastID, limit)
+	}); err != nil {
 		c.log.Printf("error exporting manifests: %v", err)
 		return nil, err
 	}
@@ -340,7 +1319,9 @@ func (c *Core) getManifests(id int, guid string, lastID, limit int, status strin
 	)
 
 	// Get the manifest.
-	if err := c.q.GetManifests.Select(&out, id, guid, lastID, limit, status); err != nil {
+	if err := timed("get-manifests", func() error {
+		return c.q.GetManifests.Select(&out, id, guid, lastID, limit, status)
+	}); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
 		}
@@ -370,66 +1351,160 @@ func (c *Core) getManifests(id int, guid string, lastID, limit int, status strin
 			o.Channels[c.GUID] = c
 		}
 
-		// Fetch entity for this manifest.
-		entity, err := c.getEntityByManifest(o.ID)
-		if err != nil && err != ErrNotFound {
-			c.log.Printf("error fetching entity for manifest %d: %v", o.ID, err)
-			return nil, err
-		}
-		if err != ErrNotFound {
-			o.Entity = entity
-		}
+		out[n] = o
+	}
 
-		// Fetch projects for this manifest.
-		projects, err := c.getProjectsByManifest(o.ID)
-		if err != nil {
-			c.log.Printf("error fetching projects for manifest %d: %v", o.ID, err)
-			return nil, err
+	// Fetch the entities and projects of all the manifests in one go instead of
+	// querying them for every manifest.
+	ids := make([]int, len(out))
+	for n, o := range out {
+		ids[n] = o.ID
+	}
+
+	entities, err := c.getEntitiesByManifests(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	projects, err := c.getProjectsByManifests(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for n, o := range out {
+		// A manifest may not have an entity.
+		if e, ok := entities[o.ID]; ok {
+			out[n].Entity = e
 		}
-		o.Projects = projects
 
-		out[n] = o
+		out[n].Projects = projects[o.ID]
 	}
 
 	return out, nil
 }
 
-// getEntityByManifest retrieves entity for a specific manifest.
-func (c *Core) getEntityByManifest(manifestID int) (models.Entity, error) {
-	var out models.Entity
-	if err := c.db.Get(&out, c.q.GetEntityByManifest, manifestID); err != nil {
-		if err == sql.ErrNoRows {
-			return models.Entity{}, ErrNotFound
-		}
-		c.log.Printf("error fetching entity by manifest: %v", err)
-		return models.Entity{}, err
+// getEntitiesByManifests retrieves the entities of one or more manifests mapped by the manifest ID.
+func (c *Core) getEntitiesByManifests(manifestIDs []int) (map[int]models.Entity, error) {
+	out := make(map[int]models.Entity, len(manifestIDs))
+	if len(manifestIDs) == 0 {
+		return out, nil
 	}
 
-	if err := out.Parse(); err != nil {
-		c.log.Printf("error parsing entity: %s: %v", out.ManifestGUID, err)
-		return models.Entity{}, err
+	var res []models.Entity
+	if err := timed("get-entities-by-manifests", func() error {
+		return c.q.GetEntitiesByManifests.Select(&res, pq.Array(manifestIDs))
+	}); err != nil {
+		c.log.Printf("error fetching entities by manifests: %v", err)
+		return nil, err
+	}
+
+	for _, e := range res {
+		if err := e.Parse(); err != nil {
+			c.log.Printf("error parsing entity: %s: %v", e.ManifestGUID, err)
+			return nil, err
+		}
+		out[e.ManifestID] = e
 	}
 
 	return out, nil
 }
 
-// getProjectsByManifest retrieves projects for a specific manifest.
-func (c *Core) getProjectsByManifest(manifestID int) (models.Projects, error) {
-	exp := strings.ReplaceAll(c.q.QueryProjectsTpl, "%query%", c.q.GetProjectsByManifest)
+// getProjectsByManifests retrieves the projects of one or more manifests mapped by the manifest ID.
+func (c *Core) getProjectsByManifests(manifestIDs []int) (map[int]models.Projects, error) {
+	out := make(map[int]models.Projects, len(manifestIDs))
+	if len(manifestIDs) == 0 {
+		return out, nil
+	}
 
-	var out models.Projects
-	if err := c.db.Select(&out, exp, manifestID); err != nil {
-		c.log.Printf("error fetching projects by manifest: %v", err)
+	var (
+		exp = strings.ReplaceAll(c.q.QueryProjectsTpl, "%query%", c.q.GetProjectsByManifests)
+		res models.Projects
+	)
+	if err := timed("get-projects-by-manifests-snippet", func() error {
+		return c.db.Select(&res, exp, pq.Array(manifestIDs))
+	}); err != nil {
+		c.log.Printf("error fetching projects by manifests: %v", err)
 		return nil, err
 	}
-	if err := out.Parse(); err != nil {
+	if err := res.Parse(); err != nil {
 		c.log.Printf("error parsing projects: %v", err)
 		return nil, err
 	}
 
+	for _, p := range res {
+		out[p.Entity.ManifestID] = append(out[p.Entity.ManifestID], p)
+	}
+
+	return out, nil
+}
+
+// timed runs a DB call and records the time it took as the duration of
+// a query (named after it in Queries).
+func timed(queryName string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	metrics.GetOrCreateSummary(fmt.Sprintf(`portal_db_query_duration_seconds{query=%q}`, queryName)).UpdateDuration(start)
+
+	return err
+}
+
+// GetCacheStats returns the counters of the cache of reads.
+func (c *Core) GetCacheStats() cache.Stats {
+	if c.opt.Cache == nil {
+		return cache.Stats{}
+	}
+
+	return c.opt.Cache.Stats()
+}
+
+// cached returns the result of a read from the cache, or runs the read and caches its result
+// tagged with the IDs of the manifests in it or cacheTagLists (if ids is given) so that it's
+// invalidated when any of them change. Errors are not cached.
+func cached[T any](c *Core, key string, read func() (T, error), ids func(T) []int) (T, error) {
+	if c.opt.Cache == nil {
+		return read()
+	}
+
+	if v, ok := c.opt.Cache.Get(key); ok {
+		return v.(T), nil
+	}
+
+	out, err := read()
+	if err != nil {
+		return out, err
+	}
+
+	var tags []int
+	if ids != nil {
+		tags = ids(out)
+	}
+	c.opt.Cache.Set(key, out, tags...)
+
 	return out, nil
 }
 
+// invalidate removes the cached reads that contain a manifest and all cached lists.
+func (c *Core) invalidate(manifestID int) {
+	if c.opt.Cache != nil {
+		c.opt.Cache.Invalidate(manifestID)
+		c.opt.Cache.Invalidate(cacheTagLists)
+	}
+}
+
+// purgeCache removes all cached reads.
+func (c *Core) purgeCache() {
+	if c.opt.Cache != nil {
+		c.opt.Cache.Purge()
+	}
+}
+
+// listTags returns the cache tag of lists. Any manifest change can affect a list (eg: recent
+// projects, top tags), including those of manifests that aren't in it, so they're all
+// invalidated together.
+func listTags[T any](T) []int {
+	return []int{cacheTagLists}
+}
+
 // MakeGUID takes a URL and creates a string "guid" in the form of
 // @$host/$uri (last 3 parts, if there are, capped at 40 chars).
 func MakeGUID(u *url.URL) string {
@@ -456,3 +1531,216 @@ func MakeGUID(u *url.URL) string {
 	guid := "@" + path.Join(u.Host, uri)
 	return guid
 }
+
+// projectFilterArgs returns the positional args for the search-projects-filter query snippet.
+func projectFilterArgs(f SearchFilter) []any {
+	return []any{f.Query, textArray(f.Tags), textArray(f.Licenses),
+		!f.Plan.IsEmpty(), f.Plan.MinAmount, f.Plan.MaxAmount, f.Plan.Currency, textArray(f.Plan.Frequencies), f.Plan.Status,
+		textArray(f.Channels), f.Plan.AmountCurrency, textArray(f.EntityTypes), textArray(f.EntityRoles)}
+}
+
+// entityFilterArgs returns the positional args for the search-entities-filter query snippet.
+func entityFilterArgs(f SearchFilter) []any {
+	return []any{f.Query, textArray(f.Channels), textArray(f.EntityTypes), textArray(f.EntityRoles)}
+}
+
+// getProjectsByIDs returns the projects with the given IDs in the same order.
+func (c *Core) getProjectsByIDs(ids []int) (models.Projects, error) {
+	if len(ids) == 0 {
+		return models.Projects{}, nil
+	}
+
+	var (
+		exp = strings.ReplaceAll(c.q.QueryProjectsTpl, "%query%", c.q.GetProjectsByIDs)
+		out models.Projects
+	)
+	if err := timed("get-projects-by-ids-snippet", func() error {
+		return c.db.Select(&out, exp, pq.Array(ids))
+	}); err != nil {
+		c.log.Printf("error fetching projects: %v", err)
+		return nil, err
+	}
+
+	if err := out.Parse(); err != nil {
+		c.log.Printf("error parsing projects: %v", err)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// getEntitiesByIDs returns the entities with the given IDs in the same order.
+func (c *Core) getEntitiesByIDs(ids []int) ([]models.Entity, error) {
+	if len(ids) == 0 {
+		return []models.Entity{}, nil
+	}
+
+	var res []models.Entity
+	if err := timed("get-entities-by-ids", func() error {
+		return c.q.GetEntitiesByIDs.Select(&res, pq.Array(ids))
+	}); err != nil {
+		c.log.Printf("error fetching entities: %v", err)
+		return nil, err
+	}
+
+	byID := make(map[int]models.Entity, len(res))
+	for _, e := range res {
+		if err := e.Parse(); err != nil {
+			c.log.Printf("error parsing entity: %s: %v", e.ManifestGUID, err)
+			return nil, err
+		}
+		byID[e.ID] = e
+	}
+
+	out := make([]models.Entity, 0, len(ids))
+	for _, id := range ids {
+		if e, ok := byID[id]; ok {
+			out = append(out, e)
+		}
+	}
+
+	return out, nil
+}
+
+// getListPage runs a listing's page query and returns the IDs of the items on the page and the cursors to the
+// pages around it. One extra row is fetched to know whether there are more items past the page.
+func (c *Core) getListPage(queryName, query string, fields map[string]listField, id string, q ListQuery) ([]int, PageCursors, error) {
+	orderBy := q.OrderBy
+	f, ok := fields[orderBy]
+	if !ok {
+		orderBy = "created_at"
+		f = fields[orderBy]
+	}
+
+	var (
+		cur     = q.After
+		reverse = q.Before != nil
+		desc    = !strings.EqualFold(q.Order, "asc")
+		sortOrd = orderBy + ":asc"
+	)
+	if desc {
+		sortOrd = orderBy + ":desc"
+	}
+	if reverse {
+		cur = q.Before
+	}
+	if cur == nil {
+		cur = &Cursor{}
+	} else if cur.Order != sortOrd {
+		// The key of a cursor from another sort order can't be cast to the type of this one.
+		return nil, PageCursors{}, ErrInvalidCursor
+	}
+
+	where, order := keyset(f.expr, f.typ, id, desc, reverse)
+	exp := strings.NewReplacer("%key%", f.expr, "%keyset%", where, "%order%", order).Replace(query)
+
+	var res []struct {
+		ID      int         `db:"id"`
+		SortKey null.String `db:"sort_key"`
+	}
+	if err := timed(queryName, func() error {
+		return c.db.Select(&res, exp, textArray(q.Channels), cur.Key, cur.ID, q.Limit+1)
+	}); err != nil {
+		return nil, PageCursors{}, err
+	}
+
+	more := len(res) > q.Limit
+	if more {
+		res = res[:q.Limit]
+	}
+	if reverse {
+		slices.Reverse(res)
+	}
+
+	var (
+		out = make([]int, 0, len(res))
+		pc  PageCursors
+	)
+	for _, r := range res {
+		out = append(out, r.ID)
+	}
+	if len(res) == 0 {
+		return out, pc, nil
+	}
+
+	var (
+		first = Cursor{Key: res[0].SortKey, ID: res[0].ID, Order: sortOrd}
+		last  = Cursor{Key: res[len(res)-1].SortKey, ID: res[len(res)-1].ID, Order: sortOrd}
+	)
+	if reverse {
+		// Going backwards, there's always the page that the cursor came from after this one.
+		pc.Next = last.String()
+		if more {
+			pc.Prev = first.String()
+		}
+	} else {
+		if more {
+			pc.Next = last.String()
+		}
+		if q.After != nil {
+			pc.Prev = first.String()
+		}
+	}
+
+	return out, pc, nil
+}
+
+// countListing returns the number of items in a listing with the given filters. Without filters,
+// the estimated total (key) from the directory stats is returned instead of counting if it's
+// at least minEstimatedTotal. Smaller or missing (not yet refreshed) totals are counted.
+func (c *Core) countListing(queryName string, stmt *sqlx.Stmt, key string, channels []string) (int, bool, error) {
+	if len(channels) == 0 {
+		var est null.Int
+		if err := timed("get-estimated-total", func() error { return c.q.GetEstimatedTotal.Get(&est, key) }); err != nil {
+			c.log.Printf("error fetching estimated %s total: %v", key, err)
+			return 0, false, err
+		}
+		if est.Valid && est.Int >= minEstimatedTotal {
+			return est.Int, true, nil
+		}
+	}
+
+	var out int
+	if err := timed(queryName, func() error { return stmt.Get(&out, textArray(channels)) }); err != nil {
+		c.log.Printf("error counting %s: %v", key, err)
+		return 0, false, err
+	}
+
+	return out, false, nil
+}
+
+// keyset returns the WHERE condition that selects the rows after a cursor (the sort key in $2 and ID in $3)
+// in a listing sorted by key (of type typ) and then id, and the ORDER BY expression. NULL keys are last.
+// With reverse, the rows before the cursor are selected in the reverse order.
+func keyset(key, typ, id string, desc, reverse bool) (string, string) {
+	var (
+		op    = ">"
+		dir   = "ASC"
+		nulls = "NULLS LAST"
+	)
+	if desc != reverse {
+		op, dir = "<", "DESC"
+	}
+
+	// Reversing the order (eg: DESC NULLS LAST => ASC NULLS FIRST) puts NULL keys first.
+	var where string
+	if reverse {
+		nulls = "NULLS FIRST"
+		where = fmt.Sprintf(`CASE WHEN $2::TEXT IS NULL THEN %[1]s IS NOT NULL OR %[2]s %[3]s $3
+			ELSE %[1]s IS NOT NULL AND (%[1]s, %[2]s) %[3]s ($2::TEXT::%[4]s, $3) END`, key, id, op, typ)
+	} else {
+		where = fmt.Sprintf(`CASE WHEN $2::TEXT IS NULL THEN %[1]s IS NULL AND %[2]s %[3]s $3
+			ELSE (%[1]s, %[2]s) %[3]s ($2::TEXT::%[4]s, $3) OR %[1]s IS NULL END`, key, id, op, typ)
+	}
+
+	return where, fmt.Sprintf("%s %s %s, %s %s", key, dir, nulls, id, dir)
+}
+
+// textArray returns a Postgres array for a list of strings. A nil list is sent as
+// an empty array instead of NULL so that CARDINALITY() checks in queries hold.
+func textArray(s []string) any {
+	if s == nil {
+		s = []string{}
+	}
+	return pq.Array(s)
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/floss-fund/portal/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/goyesql/v2"
+	goyesqlx "github.com/knadh/goyesql/v2/sqlx"
+)
+
+// benchCore returns a Core connected to the database in the PORTAL_TEST_DSN environment
+// variable (eg: "host=localhost user=portal password=portal dbname=portal sslmode=disable").
+// The benchmark is skipped if it isn't set.
+func benchCore(b *testing.B) *Core {
+	dsn := os.Getenv("PORTAL_TEST_DSN")
+	if dsn == "" {
+		b.Skip("PORTAL_TEST_DSN is not set")
+	}
+
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		b.Fatalf("error connecting to DB: %v", err)
+	}
+	b.Cleanup(func() { db.Close() })
+
+	qMap, err := goyesql.ParseFile("../../queries.sql")
+	if err != nil {
+		b.Fatalf("error loading SQL queries: %v", err)
+	}
+
+	var q Queries
+	if err := goyesqlx.ScanToStruct(&q, qMap, db.Unsafe()); err != nil {
+		b.Fatalf("error preparing SQL queries: %v", err)
+	}
+
+	return New(&q, db, Opt{SearchLanguages: []string{"english"}}, log.New(io.Discard, "", 0))
+}
+
+// BenchmarkGetManifests fetches a page of manifests with their entities and projects
+// batch-loaded with one query each.
+func BenchmarkGetManifests(b *testing.B) {
+	c := benchCore(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.GetManifests(0, 50, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGetManifestsPerManifest fetches the same page, but loads the entity and
+// projects of every manifest with separate queries, as it used to be done, for comparison.
+func BenchmarkGetManifestsPerManifest(b *testing.B) {
+	c := benchCore(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var out []models.ManifestData
+		if err := c.q.GetManifests.Select(&out, 0, "", 0, 50, ""); err != nil {
+			b.Fatal(err)
+		}
+
+		for _, m := range out {
+			if _, err := c.getEntitiesByManifests([]int{m.ID}); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := c.getProjectsByManifests([]int{m.ID}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/volatiletech/null.v6"
 )
 
 func TestMakeGUID(t *testing.T) {
@@ -27,3 +28,52 @@ func TestMakeGUID(t *testing.T) {
 	f("https://example.com/single", "@example.com/single")
 	f("https://sub.domain.example.com/project", "@sub.domain.example.com/project")
 }
+
+func TestKeyset(t *testing.T) {
+	where, order := keyset("p.name", "TEXT", "p.id", true, false)
+	assert.Equal(t, "p.name DESC NULLS LAST, p.id DESC", order)
+	assert.Contains(t, where, "(p.name, p.id) < ($2::TEXT::TEXT, $3) OR p.name IS NULL")
+
+	// Going backwards reverses the order and puts NULLs first.
+	where, order = keyset("p.name", "TEXT", "p.id", true, true)
+	assert.Equal(t, "p.name ASC NULLS FIRST, p.id ASC", order)
+	assert.Contains(t, where, "p.name IS NOT NULL AND (p.name, p.id) > ($2::TEXT::TEXT, $3)")
+
+	_, order = keyset("es.num_plans", "BIGINT", "e.id", false, false)
+	assert.Equal(t, "es.num_plans ASC NULLS LAST, e.id ASC", order)
+}
+
+func TestCursor(t *testing.T) {
+	c := Cursor{Key: null.StringFrom("2024-10-01 10:00:00.123+00"), ID: 42, Order: "created_at:desc"}
+	out, err := ParseCursor(c.String())
+	assert.NoError(t, err)
+	assert.Equal(t, c, *out)
+
+	// NULL sort keys.
+	c = Cursor{ID: 7}
+	out, err = ParseCursor(c.String())
+	assert.NoError(t, err)
+	assert.False(t, out.Key.Valid)
+
+	for _, s := range []string{"", "not-a-cursor", "e30"} {
+		_, err := ParseCursor(s)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	}
+}
+
+func TestListPageCursorOrder(t *testing.T) {
+	c := &Core{}
+
+	// Cursors from another sort field or direction are rejected before querying.
+	for _, cur := range []Cursor{
+		{Key: null.StringFrom("foo"), ID: 1, Order: "name:desc"},
+		{Key: null.StringFrom("2024-10-01"), ID: 1, Order: "created_at:asc"},
+		{Key: null.StringFrom("2024-10-01"), ID: 1},
+	} {
+		_, _, err := c.getListPage("", "", projectListOrderFields, "p.id", ListQuery{OrderBy: ProjectOrderCreated, Order: "desc", After: &cur})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+
+		_, _, err = c.getListPage("", "", projectListOrderFields, "p.id", ListQuery{OrderBy: ProjectOrderCreated, Order: "desc", Before: &cur})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"log"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/floss-fund/go-funding-json/common"
 	"github.com/floss-fund/portal/internal/models"
 )
@@ -43,6 +45,10 @@ type Crawl struct {
 	wg   *sync.WaitGroup
 	jobs chan models.ManifestJob
 
+	// Whether the crawler is running and the last time (unix nanoseconds) that it made progress.
+	running    atomic.Bool
+	lastActive atomic.Int64
+
 	hc  *common.HTTPClient
 	log *log.Logger
 }
@@ -56,7 +62,7 @@ var (
 )
 
 func New(o *Opt, sc Schema, cb *Callbacks, db DB, l *log.Logger) *Crawl {
-	return &Crawl{
+	c := &Crawl{
 		opt:       o,
 		sc:        sc,
 		Callbacks: cb,
@@ -67,9 +73,20 @@ func New(o *Opt, sc Schema, cb *Callbacks, db DB, l *log.Logger) *Crawl {
 		jobs: make(chan models.ManifestJob, o.BatchSize),
 		log:  l,
 	}
+
+	// Number of manifests queued for crawling.
+	metrics.GetOrCreateGauge("portal_crawl_queue_depth", func() float64 {
+		return float64(len(c.jobs))
+	})
+
+	return c
 }
 
 func (c *Crawl) Crawl() error {
+	c.running.Store(true)
+	defer c.running.Store(false)
+	c.touch()
+
 	for n := 0; n < c.opt.Workers; n++ {
 		c.wg.Add(1)
 
@@ -82,6 +99,17 @@ func (c *Crawl) Crawl() error {
 	return nil
 }
 
+// Status returns whether the crawler is running and the last time that it made progress
+// (fetched a batch of manifests or crawled one).
+func (c *Crawl) Status() (bool, time.Time) {
+	return c.running.Load(), time.Unix(0, c.lastActive.Load())
+}
+
+// touch records that the crawler made progress.
+func (c *Crawl) touch() {
+	c.lastActive.Store(time.Now().UnixNano())
+}
+
 // IsManifestModified sends a head request to a manifest URL and
 // indicates whether it's been updated (true=needs re-crawling).
 func (c *Crawl) IsManifestModified(manifest *url.URL, lastModified time.Time) (bool, error) {
@@ -1,8 +1,10 @@
 package crawl
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/floss-fund/portal/internal/core"
 	"github.com/floss-fund/portal/internal/models"
 )
@@ -19,6 +21,7 @@ func (c *Crawl) dbWorker() {
 			time.Sleep(time.Second * 5)
 			continue
 		}
+		c.touch()
 
 		// No more items. End fetch.
 		if len(items) == 0 {
@@ -44,10 +47,13 @@ func (c *Crawl) dbWorker() {
 
 func (c *Crawl) worker() {
 	for j := range c.jobs {
+		c.touch()
+
 		// Fetch and validate the manifest.
 		reCrawl, err := c.IsManifestModified(j.URLobj, j.LastModified)
 		if err != nil {
 			c.log.Printf("error fetching modified date: %s: %v", j.URL, err)
+			countJob("check_error")
 
 			// Record the error.
 			if status, err := c.db.UpdateManifestCrawlError(j.ID, err.Error(), c.opt.MaxCrawlErrors, c.opt.DisableOnErrros); err == nil {
@@ -62,6 +68,7 @@ func (c *Crawl) worker() {
 
 		if !reCrawl {
 			c.log.Printf("no modification. Skipping: %s", j.URL)
+			countJob("unchanged")
 
 			// Touch and update its date.
 			_ = c.db.UpdateManifestDate(j.ID)
@@ -75,6 +82,7 @@ func (c *Crawl) worker() {
 		m.ID = j.ID
 		if err != nil {
 			c.log.Printf("error crawling: %s: %v", j.URL, err)
+			countJob("fetch_error")
 
 			// Record the error.
 			status, _ = c.db.UpdateManifestCrawlError(j.ID, err.Error(), c.opt.MaxCrawlErrors, c.opt.DisableOnErrros)
@@ -88,8 +96,10 @@ func (c *Crawl) worker() {
 		// Add it to the database.
 		if err := c.db.UpsertManifest(m, status); err != nil {
 			c.log.Printf("error upserting manifest: %s: %v", j.URL, err)
+			countJob("db_error")
 			continue
 		}
+		countJob("updated")
 
 		if c.Callbacks != nil && c.Callbacks.OnManifestUpdate != nil {
 			c.Callbacks.OnManifestUpdate(m, status)
@@ -98,3 +108,8 @@ func (c *Crawl) worker() {
 
 	c.wg.Done()
 }
+
+// countJob counts a crawl job by its outcome.
+func countJob(outcome string) {
+	metrics.GetOrCreateCounter(fmt.Sprintf(`portal_crawl_jobs_total{outcome=%q}`, outcome)).Inc()
+}
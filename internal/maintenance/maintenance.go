@@ -0,0 +1,63 @@
+// Package maintenance runs periodic DB maintenance tasks such as
+// refreshing the materialized views of aggregates.
+package maintenance
+
+import (
+	"log"
+	"time"
+)
+
+type DB interface {
+	RefreshView(name string) error
+}
+
+type Opt struct {
+	// Materialized views to refresh.
+	Views []string `json:"views"`
+
+	// Interval at which the scheduler runs the tasks.
+	Interval time.Duration `json:"interval"`
+}
+
+type Maintenance struct {
+	opt *Opt
+	db  DB
+	log *log.Logger
+}
+
+func New(o *Opt, db DB, l *log.Logger) *Maintenance {
+	return &Maintenance{
+		opt: o,
+		db:  db,
+		log: l,
+	}
+}
+
+// Run runs all maintenance tasks once. An error in a task doesn't stop
+// the others from running and the last error is returned.
+func (m *Maintenance) Run() error {
+	var lastErr error
+	for _, v := range m.opt.Views {
+		start := time.Now()
+		if err := m.db.RefreshView(v); err != nil {
+			m.log.Printf("error refreshing view %s: %v", v, err)
+			lastErr = err
+			continue
+		}
+
+		m.log.Printf("refreshed view %s in %v", v, time.Since(start).Round(time.Millisecond))
+	}
+
+	return lastErr
+}
+
+// Schedule runs the maintenance tasks at every interval. It blocks forever
+// and is meant to be run in a goroutine.
+func (m *Maintenance) Schedule() {
+	t := time.NewTicker(m.opt.Interval)
+	defer t.Stop()
+
+	for range t.C {
+		m.Run()
+	}
+}
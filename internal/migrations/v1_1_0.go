@@ -0,0 +1,405 @@
+package migrations
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/stuffbin"
+	"github.com/lib/pq"
+)
+
+// V1_1_0 performs the DB migrations.
+func V1_1_0(db *sqlx.DB, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
+	// Normalised funding tables.
+	if _, err := db.Exec(`
+		CREATE OR REPLACE FUNCTION TO_JSONB_ARRAY(v JSONB) RETURNS JSONB LANGUAGE SQL IMMUTABLE AS $$
+			SELECT CASE WHEN JSONB_TYPEOF(v) = 'array' THEN v ELSE '[]'::JSONB END
+		$$;
+
+		DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'channel_type') THEN
+				CREATE TYPE channel_type AS ENUM ('bank', 'payment-provider', 'cheque', 'cash', 'other');
+			END IF;
+			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'plan_status') THEN
+				CREATE TYPE plan_status AS ENUM ('active', 'inactive');
+			END IF;
+			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'plan_frequency') THEN
+				CREATE TYPE plan_frequency AS ENUM ('one-time', 'weekly', 'fortnightly', 'monthly', 'yearly', 'other');
+			END IF;
+		END$$;
+
+		CREATE TABLE IF NOT EXISTS funding_channels (
+			id                  SERIAL PRIMARY KEY,
+			manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			guid                TEXT NOT NULL,
+			type                channel_type NOT NULL,
+			address             TEXT NOT NULL DEFAULT '',
+			description         TEXT NOT NULL DEFAULT '',
+			ord                 INT NOT NULL DEFAULT 0,
+			created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			updated_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_channel_guid ON funding_channels(manifest_id, guid);
+		CREATE INDEX IF NOT EXISTS idx_channel_type ON funding_channels(type);
+
+		CREATE TABLE IF NOT EXISTS funding_plans (
+			id                  SERIAL PRIMARY KEY,
+			manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			guid                TEXT NOT NULL,
+			status              plan_status NOT NULL,
+			name                TEXT NOT NULL,
+			description         TEXT NOT NULL DEFAULT '',
+			amount              NUMERIC NOT NULL DEFAULT 0,
+			currency            TEXT NOT NULL,
+			frequency           plan_frequency NOT NULL,
+			ord                 INT NOT NULL DEFAULT 0,
+			created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			updated_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_plan_guid ON funding_plans(manifest_id, guid);
+		CREATE INDEX IF NOT EXISTS idx_plan_amount ON funding_plans(currency, amount);
+		CREATE INDEX IF NOT EXISTS idx_plan_frequency ON funding_plans(frequency);
+
+		CREATE TABLE IF NOT EXISTS funding_plan_channels (
+			plan_id             INTEGER NOT NULL REFERENCES funding_plans(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			channel_id          INTEGER NOT NULL REFERENCES funding_channels(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			ord                 INT NOT NULL DEFAULT 0,
+			PRIMARY KEY (plan_id, channel_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_plan_channels_channel ON funding_plan_channels(channel_id);
+
+		CREATE TABLE IF NOT EXISTS funding_history (
+			id                  SERIAL PRIMARY KEY,
+			manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			year                INT NOT NULL,
+			income              NUMERIC NOT NULL DEFAULT 0,
+			expenses            NUMERIC NOT NULL DEFAULT 0,
+			taxes               NUMERIC NOT NULL DEFAULT 0,
+			currency            TEXT NOT NULL,
+			description         TEXT NOT NULL DEFAULT '',
+			ord                 INT NOT NULL DEFAULT 0,
+			created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_history_manifest ON funding_history(manifest_id, year);
+
+		-- The funding JSONB blob is no longer queried.
+		DROP INDEX IF EXISTS idx_funding_channels;
+		DROP INDEX IF EXISTS idx_funding_plans;
+		DROP INDEX IF EXISTS idx_funding_history;
+	`); err != nil {
+		return err
+	}
+
+	// Exchange rates.
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS exchange_rates (
+			currency            TEXT NOT NULL PRIMARY KEY,
+			rate                NUMERIC NOT NULL CHECK (rate > 0),
+			updated_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		);
+
+		CREATE OR REPLACE FUNCTION CONVERT_AMOUNT(amount NUMERIC, src TEXT, dst TEXT) RETURNS NUMERIC LANGUAGE SQL STABLE AS $$
+			SELECT CASE
+				WHEN COALESCE(dst, '') = '' OR src = dst THEN amount
+				ELSE amount / (SELECT rate FROM exchange_rates WHERE currency = src) * (SELECT rate FROM exchange_rates WHERE currency = dst)
+			END
+		$$;
+	`); err != nil {
+		return err
+	}
+
+	// Populate the funding tables from the existing manifests.
+	if _, err := db.Exec(`
+		INSERT INTO funding_channels (manifest_id, guid, type, address, description, ord)
+		SELECT m.id, c->>'guid', (c->>'type')::channel_type, COALESCE(c->>'address', ''), COALESCE(c->>'description', ''), ord
+		FROM manifests m, JSONB_ARRAY_ELEMENTS(TO_JSONB_ARRAY(m.funding->'channels')) WITH ORDINALITY AS t(c, ord)
+		ON CONFLICT (manifest_id, guid) DO NOTHING;
+
+		INSERT INTO funding_plans (manifest_id, guid, status, name, description, amount, currency, frequency, ord)
+		SELECT m.id, p->>'guid', (p->>'status')::plan_status, p->>'name', COALESCE(p->>'description', ''),
+			COALESCE((p->>'amount')::NUMERIC, 0), p->>'currency', (p->>'frequency')::plan_frequency, ord
+		FROM manifests m, JSONB_ARRAY_ELEMENTS(TO_JSONB_ARRAY(m.funding->'plans')) WITH ORDINALITY AS t(p, ord)
+		ON CONFLICT (manifest_id, guid) DO NOTHING;
+
+		INSERT INTO funding_plan_channels (plan_id, channel_id, ord)
+		SELECT DISTINCT ON (pl.id, ch.id) pl.id, ch.id, pc.ord
+		FROM manifests m
+		CROSS JOIN JSONB_ARRAY_ELEMENTS(TO_JSONB_ARRAY(m.funding->'plans')) AS np
+		JOIN funding_plans pl ON pl.manifest_id = m.id AND pl.guid = np->>'guid'
+		CROSS JOIN JSONB_ARRAY_ELEMENTS_TEXT(TO_JSONB_ARRAY(np->'channels')) WITH ORDINALITY AS pc(guid, ord)
+		JOIN funding_channels ch ON ch.manifest_id = m.id AND ch.guid = pc.guid
+		ON CONFLICT (plan_id, channel_id) DO NOTHING;
+
+		INSERT INTO funding_history (manifest_id, year, income, expenses, taxes, currency, description, ord)
+		SELECT m.id, (h->>'year')::INT, COALESCE((h->>'income')::NUMERIC, 0), COALESCE((h->>'expenses')::NUMERIC, 0),
+			COALESCE((h->>'taxes')::NUMERIC, 0), h->>'currency', COALESCE(h->>'description', ''), ord
+		FROM manifests m, JSONB_ARRAY_ELEMENTS(TO_JSONB_ARRAY(m.funding->'history')) WITH ORDINALITY AS t(h, ord)
+		WHERE NOT EXISTS (SELECT 1 FROM funding_history WHERE manifest_id = m.id);
+	`); err != nil {
+		return err
+	}
+
+	// Tag normalisation, aliases, and taxonomy.
+	if _, err := db.Exec(`
+		CREATE OR REPLACE FUNCTION NORMALIZE_TAG(t TEXT) RETURNS TEXT LANGUAGE SQL IMMUTABLE AS $$
+			SELECT TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(TRIM(t)), '[^[:alnum:]+#.]+', '-', 'g'))
+		$$;
+
+		CREATE TABLE IF NOT EXISTS tag_aliases (
+			alias               TEXT NOT NULL PRIMARY KEY CHECK (alias = NORMALIZE_TAG(alias)),
+			tag                 TEXT NOT NULL CHECK (tag = NORMALIZE_TAG(tag) AND tag != alias),
+			created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_tag_aliases_tag ON tag_aliases(tag);
+
+		CREATE TABLE IF NOT EXISTS tag_taxonomy (
+			tag                 TEXT NOT NULL PRIMARY KEY CHECK (tag = NORMALIZE_TAG(tag)),
+			parent              TEXT NULL CHECK (parent = NORMALIZE_TAG(parent) AND parent != tag),
+			created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_tag_taxonomy_parent ON tag_taxonomy(parent);
+
+		CREATE OR REPLACE FUNCTION CANONICAL_TAGS(tags TEXT[]) RETURNS TEXT[] LANGUAGE SQL STABLE AS $$
+			SELECT COALESCE(ARRAY_AGG(DISTINCT COALESCE(a.tag, n.tag)), '{}')
+			FROM (SELECT NORMALIZE_TAG(t) AS tag FROM UNNEST(tags) AS t) n
+			LEFT JOIN tag_aliases a ON a.alias = n.tag
+			WHERE n.tag != ''
+		$$;
+
+		CREATE OR REPLACE FUNCTION TAGS_WITH_DESCENDANTS(tags TEXT[]) RETURNS TEXT[] LANGUAGE SQL STABLE AS $$
+			WITH RECURSIVE d AS (
+				SELECT UNNEST(tags) AS tag
+				UNION
+				SELECT t.tag FROM tag_taxonomy t JOIN d ON t.parent = d.tag
+			)
+			SELECT COALESCE(ARRAY_AGG(tag), '{}') FROM d
+		$$;
+
+		ALTER TABLE projects ADD COLUMN IF NOT EXISTS tags_canonical TEXT[] NOT NULL DEFAULT '{}';
+		UPDATE projects SET tags_canonical = CANONICAL_TAGS(tags) WHERE tags_canonical IS DISTINCT FROM CANONICAL_TAGS(tags);
+		CREATE INDEX IF NOT EXISTS idx_project_tags_canonical ON projects USING GIN (tags_canonical);
+
+		-- Top tags are now aggregated from the canonical tags.
+		DROP MATERIALIZED VIEW IF EXISTS top_tags;
+		CREATE MATERIALIZED VIEW top_tags AS
+			SELECT unnest(tags_canonical) AS tag, COUNT(*) AS tag_count FROM projects GROUP BY unnest(tags_canonical) ORDER BY tag_count DESC LIMIT 1000;
+	`); err != nil {
+		return err
+	}
+
+	// Directory stats.
+	if _, err := db.Exec(`
+		CREATE MATERIALIZED VIEW IF NOT EXISTS directory_stats AS
+		WITH act AS (SELECT id, created_at FROM manifests WHERE status = 'active')
+		-- Totals.
+		SELECT 'totals' AS stat, 'manifests' AS key, '' AS currency, COUNT(*)::NUMERIC AS value FROM act
+		UNION ALL SELECT 'totals', 'projects', '', COUNT(*) FROM projects p JOIN act ON act.id = p.manifest_id
+		UNION ALL SELECT 'totals', 'plans', '', COUNT(*) FROM funding_plans fp JOIN act ON act.id = fp.manifest_id WHERE fp.status = 'active'
+		UNION ALL SELECT 'totals', 'channels', '', COUNT(*) FROM funding_channels fc JOIN act ON act.id = fc.manifest_id
+		-- Manifests by status (all manifests).
+		UNION ALL SELECT 'manifests_status', status::TEXT, '', COUNT(*) FROM manifests GROUP BY status
+		-- Entities by type and role.
+		UNION ALL SELECT 'entities_type', e.type::TEXT, '', COUNT(*) FROM entities e JOIN act ON act.id = e.manifest_id GROUP BY e.type
+		UNION ALL SELECT 'entities_role', e.role::TEXT, '', COUNT(*) FROM entities e JOIN act ON act.id = e.manifest_id GROUP BY e.role
+		-- Top projects by license and tag.
+		UNION ALL SELECT 'projects_license', key, '', num FROM (
+		    SELECT UNNEST(p.licenses) AS key, COUNT(*) AS num FROM projects p JOIN act ON act.id = p.manifest_id
+		    GROUP BY key ORDER BY num DESC LIMIT 50
+		) l
+		UNION ALL SELECT 'projects_tag', key, '', num FROM (
+		    SELECT UNNEST(p.tags_canonical) AS key, COUNT(*) AS num FROM projects p JOIN act ON act.id = p.manifest_id
+		    GROUP BY key ORDER BY num DESC LIMIT 50
+		) t
+		-- Active plans by frequency.
+		UNION ALL SELECT 'plans_frequency', fp.frequency::TEXT, '', COUNT(*)
+		    FROM funding_plans fp JOIN act ON act.id = fp.manifest_id WHERE fp.status = 'active' GROUP BY fp.frequency
+		-- Sum of requested funding per month (recurring active plans) in each currency.
+		UNION ALL SELECT 'plans_monthly', '', fp.currency, SUM(
+		    CASE fp.frequency
+		        WHEN 'weekly' THEN fp.amount * 52 / 12
+		        WHEN 'fortnightly' THEN fp.amount * 26 / 12
+		        WHEN 'monthly' THEN fp.amount
+		        WHEN 'yearly' THEN fp.amount / 12
+		    END)
+		    FROM funding_plans fp JOIN act ON act.id = fp.manifest_id
+		    WHERE fp.status = 'active' AND fp.frequency IN ('weekly', 'fortnightly', 'monthly', 'yearly')
+		    GROUP BY fp.currency
+		-- Yearly income and expenses from the financial history in each currency.
+		UNION ALL SELECT 'history_income', h.year::TEXT, h.currency, SUM(h.income)
+		    FROM funding_history h JOIN act ON act.id = h.manifest_id GROUP BY h.year, h.currency
+		UNION ALL SELECT 'history_expenses', h.year::TEXT, h.currency, SUM(h.expenses)
+		    FROM funding_history h JOIN act ON act.id = h.manifest_id GROUP BY h.year, h.currency
+		-- New listings per month.
+		UNION ALL SELECT 'listings_month', TO_CHAR(DATE_TRUNC('month', created_at), 'YYYY-MM'), '', COUNT(*)
+		    FROM act GROUP BY 2;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_directory_stats ON directory_stats(stat, key, currency);
+
+		-- Unique index for REFRESH MATERIALIZED VIEW CONCURRENTLY.
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_top_tags ON top_tags(tag);
+	`); err != nil {
+		return err
+	}
+
+	// Prefix and fuzzy search.
+	if _, err := db.Exec(`
+		CREATE OR REPLACE FUNCTION PREFIX_TSQUERY(q TEXT) RETURNS TSQUERY LANGUAGE SQL IMMUTABLE AS $$
+			SELECT COALESCE(TO_TSQUERY('simple', STRING_AGG(QUOTE_LITERAL(w) || ':*', ' & ')), ''::TSQUERY)
+			FROM REGEXP_SPLIT_TO_TABLE(LOWER(q), '[^[:alnum:]]+') AS w WHERE w != ''
+		$$;
+
+		CREATE MATERIALIZED VIEW IF NOT EXISTS search_words AS
+			SELECT word, ndoc FROM TS_STAT('SELECT search_tokens FROM projects UNION ALL SELECT search_tokens FROM entities');
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_search_words ON search_words(word);
+		CREATE INDEX IF NOT EXISTS idx_search_words_trgm ON search_words USING GIN (word gin_trgm_ops);
+	`); err != nil {
+		return err
+	}
+
+	// Language aware full-text search.
+	if _, err := db.Exec(`
+		CREATE OR REPLACE FUNCTION PREFIX_TSQUERY(q TEXT, langs REGCONFIG[]) RETURNS TSQUERY LANGUAGE SQL IMMUTABLE AS $$
+			WITH words AS (
+				SELECT STRING_AGG(QUOTE_LITERAL(w) || ':*', ' & ') AS w
+				FROM REGEXP_SPLIT_TO_TABLE(LOWER(q), '[^[:alnum:]]+') AS w WHERE w != ''
+			)
+			SELECT COALESCE(STRING_AGG('(' || t.tq || ')', ' | ')::TSQUERY, ''::TSQUERY)
+			FROM words, (SELECT DISTINCT UNNEST(langs || 'simple'::REGCONFIG) AS cfg) l,
+			LATERAL (SELECT TO_TSQUERY(l.cfg, words.w)::TEXT AS tq) t
+			WHERE words.w IS NOT NULL AND t.tq != ''
+		$$;
+		DROP FUNCTION IF EXISTS PREFIX_TSQUERY(TEXT);
+
+		CREATE OR REPLACE FUNCTION DETECT_LANGUAGE(t TEXT, langs REGCONFIG[]) RETURNS REGCONFIG LANGUAGE SQL STABLE AS $$
+			SELECT COALESCE((
+				SELECT u.l FROM UNNEST(langs) WITH ORDINALITY AS u(l, ord),
+				LATERAL (SELECT COUNT(*) AS n FROM TS_DEBUG(u.l, COALESCE(t, '')) WHERE lexemes = '{}') s
+				WHERE s.n > 0 ORDER BY s.n DESC, u.ord LIMIT 1
+			), langs[1], 'simple'::REGCONFIG)
+		$$;
+
+		ALTER TABLE manifests ADD COLUMN IF NOT EXISTS language REGCONFIG NULL;
+		ALTER TABLE entities ADD COLUMN IF NOT EXISTS search_lang REGCONFIG NOT NULL DEFAULT 'simple';
+		ALTER TABLE projects ADD COLUMN IF NOT EXISTS search_lang REGCONFIG NOT NULL DEFAULT 'simple';
+	`); err != nil {
+		return err
+	}
+
+	// Aggregates and indexes for sorting listings.
+	if _, err := db.Exec(`
+		CREATE MATERIALIZED VIEW IF NOT EXISTS entity_stats AS
+		SELECT m.id AS manifest_id,
+			(SELECT COUNT(*) FROM projects p WHERE p.manifest_id = m.id) AS num_projects,
+			(SELECT COUNT(*) FROM funding_plans fp WHERE fp.manifest_id = m.id AND fp.status = 'active') AS num_plans,
+			COALESCE((
+				SELECT SUM(CONVERT_AMOUNT(
+					CASE fp.frequency
+						WHEN 'weekly' THEN fp.amount * 52 / 12
+						WHEN 'fortnightly' THEN fp.amount * 26 / 12
+						WHEN 'monthly' THEN fp.amount
+						WHEN 'yearly' THEN fp.amount / 12
+					END, fp.currency, base.currency))
+				FROM funding_plans fp
+				WHERE fp.manifest_id = m.id AND fp.status = 'active' AND fp.frequency IN ('weekly', 'fortnightly', 'monthly', 'yearly')
+					AND base.currency IS NOT NULL
+			), 0) AS monthly_funding
+		FROM manifests m
+		LEFT JOIN (SELECT currency FROM exchange_rates WHERE rate = 1 ORDER BY currency LIMIT 1) base ON TRUE;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_entity_stats ON entity_stats(manifest_id);
+
+		CREATE INDEX IF NOT EXISTS idx_manifest_updated ON manifests(updated_at);
+		CREATE INDEX IF NOT EXISTS idx_entity_name_sort ON entities(LOWER(name), id);
+		CREATE INDEX IF NOT EXISTS idx_entity_name_sort_desc ON entities(LOWER(name) DESC NULLS LAST, id DESC);
+		CREATE INDEX IF NOT EXISTS idx_project_name_sort ON projects(name, id);
+		CREATE INDEX IF NOT EXISTS idx_project_name_sort_desc ON projects(name DESC NULLS LAST, id DESC);
+		CREATE INDEX IF NOT EXISTS idx_project_license_sort ON projects((licenses[1]), id);
+		CREATE INDEX IF NOT EXISTS idx_project_license_sort_desc ON projects((licenses[1]) DESC NULLS LAST, id DESC);
+	`); err != nil {
+		return err
+	}
+
+	// Regenerate the search tokens in each manifest's detected language.
+	if err := regenSearchTokens(db, ko); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// regenSearchTokens regenerates the search tokens of entities and projects in each
+// manifest's detected language in a transaction. It's skipped if the generated columns
+// already use the per-manifest language.
+func regenSearchTokens(db *sqlx.DB, ko *koanf.Koanf) error {
+	var done bool
+	if err := db.Get(&done, `SELECT EXISTS(SELECT 1 FROM information_schema.columns
+		WHERE table_name = 'projects' AND column_name = 'search_tokens' AND generation_expression LIKE '%search_lang%')`); err != nil {
+		return err
+	}
+	if done {
+		return nil
+	}
+
+	langs := ko.Strings("site.search_languages")
+	if len(langs) == 0 {
+		langs = []string{"english"}
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Drop the generated columns first so that they aren't computed twice.
+	if _, err := tx.Exec(`
+		ALTER TABLE entities DROP COLUMN IF EXISTS search_tokens;
+		ALTER TABLE projects DROP COLUMN IF EXISTS search_tokens;
+	`); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		WITH lang AS (
+			SELECT m.id, COALESCE(m.language, DETECT_LANGUAGE(
+				CONCAT_WS(' ', (SELECT description FROM entities WHERE manifest_id = m.id),
+					(SELECT STRING_AGG(description, ' ') FROM projects WHERE manifest_id = m.id)),
+				$1::TEXT[]::REGCONFIG[]
+			)) AS lang FROM manifests m
+		),
+		ent AS (
+			UPDATE entities e SET search_lang = lang.lang FROM lang WHERE e.manifest_id = lang.id
+		)
+		UPDATE projects p SET search_lang = lang.lang FROM lang WHERE p.manifest_id = lang.id
+	`, pq.Array(langs)); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		ALTER TABLE entities ADD COLUMN search_tokens TSVECTOR
+		GENERATED ALWAYS AS (
+			SETWEIGHT(TO_TSVECTOR(search_lang, COALESCE(name, '')), 'A') ||
+			SETWEIGHT(TO_TSVECTOR(search_lang, COALESCE(description, '')), 'B')
+		) STORED;
+		CREATE INDEX IF NOT EXISTS idx_entities_search ON entities USING GIN (search_tokens);
+
+		ALTER TABLE projects ADD COLUMN search_tokens TSVECTOR
+		GENERATED ALWAYS AS (
+			SETWEIGHT(TO_TSVECTOR(search_lang, COALESCE(name, '')), 'A') ||
+			SETWEIGHT(TO_TSVECTOR(search_lang, COALESCE(description, '')), 'B')
+		) STORED;
+		CREATE INDEX IF NOT EXISTS idx_projects_search ON projects USING GIN (search_tokens);
+
+		-- "Did you mean" suggestions are made from the unstemmed words.
+		DROP MATERIALIZED VIEW IF EXISTS search_words;
+		CREATE MATERIALIZED VIEW search_words AS
+			SELECT word, ndoc FROM TS_STAT($q$
+				SELECT TO_TSVECTOR('simple', CONCAT_WS(' ', name, description)) FROM projects
+				UNION ALL SELECT TO_TSVECTOR('simple', CONCAT_WS(' ', name, description)) FROM entities
+			$q$);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_search_words ON search_words(word);
+		CREATE INDEX IF NOT EXISTS idx_search_words_trgm ON search_words USING GIN (word gin_trgm_ops);
+	`); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -26,6 +26,130 @@ type ManifestJob struct {
 	URLobj *url.URL `json:"-" db:"-"`
 }
 
+// ExchangeRates is a map of currency codes to their rates relative to a common base currency.
+type ExchangeRates map[string]float64
+
+// CanConvert returns true if an amount can be converted between the given currencies.
+func (r ExchangeRates) CanConvert(from, to string) bool {
+	if from == to {
+		return true
+	}
+
+	return r[from] > 0 && r[to] > 0
+}
+
+// Convert converts an amount from one currency to another. If there are
+// no rates for either currency, the amount is returned as-is.
+func (r ExchangeRates) Convert(amount float64, from, to string) float64 {
+	if from == to || !r.CanConvert(from, to) {
+		return amount
+	}
+
+	return amount / r[from] * r[to]
+}
+
+// Facet is the number of results for a particular filter value.
+type Facet struct {
+	Value string `db:"value" json:"value"`
+	Count int    `db:"count" json:"count"`
+}
+
+// TermCount is the number of projects for a tag or license along with
+// the total number of tags or licenses for pagination.
+type TermCount struct {
+	Facet
+	Total int `db:"total" json:"-"`
+}
+
+// TagAlias maps a tag variant to a canonical tag.
+type TagAlias struct {
+	Alias     string    `db:"alias" json:"alias"`
+	Tag       string    `db:"tag" json:"tag"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// TagNode is a tag in the tag taxonomy. Parent is empty for top level tags.
+type TagNode struct {
+	Tag       string    `db:"tag" json:"tag"`
+	Parent    string    `db:"parent" json:"parent"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// AutocompleteItem is a project, entity, or tag that matches a partial search query.
+type AutocompleteItem struct {
+	Type  string `db:"type" json:"-"`
+	GUID  string `db:"guid" json:"guid"`
+	Name  string `db:"name" json:"name"`
+	Count int    `db:"count" json:"count,omitempty"`
+	URL   string `db:"-" json:"url"`
+}
+
+// Autocomplete represents autocomplete suggestions for a search query.
+type Autocomplete struct {
+	Projects []AutocompleteItem `json:"projects"`
+	Entities []AutocompleteItem `json:"entities"`
+	Tags     []AutocompleteItem `json:"tags"`
+}
+
+// SearchResult is a project or an entity in a search across both.
+type SearchResult struct {
+	Type    string   `json:"type"`
+	Project *Project `json:"project,omitempty"`
+	Entity  *Entity  `json:"entity,omitempty"`
+}
+
+// SearchCounts is the number of projects and entities that match a search.
+type SearchCounts struct {
+	Projects int `db:"num_projects" json:"projects"`
+	Entities int `db:"num_entities" json:"entities"`
+}
+
+// Stats represents aggregate statistics of the directory.
+type Stats struct {
+	Totals            map[string]int `json:"totals"`
+	ManifestsByStatus []Facet        `json:"manifests_by_status"`
+	EntitiesByType    []Facet        `json:"entities_by_type"`
+	EntitiesByRole    []Facet        `json:"entities_by_role"`
+	ProjectsByLicense []Facet        `json:"projects_by_license"`
+	ProjectsByTag     []Facet        `json:"projects_by_tag"`
+	PlansByFrequency  []Facet        `json:"plans_by_frequency"`
+
+	// Amounts are normalised to Currency. Amounts in currencies
+	// without exchange rates are skipped and listed in SkippedCurrencies.
+	Currency          string        `json:"currency"`
+	MonthlyFunding    float64       `json:"monthly_funding"`
+	History           []StatHistory `json:"history"`
+	SkippedCurrencies []string      `json:"skipped_currencies"`
+
+	Growth []StatGrowth `json:"growth"`
+}
+
+// StatHistory is the total income and expenses of all listings in a year.
+type StatHistory struct {
+	Year     string  `json:"year"`
+	Income   float64 `json:"income"`
+	Expenses float64 `json:"expenses"`
+}
+
+// StatGrowth is the number of new listings in a month and the running total.
+type StatGrowth struct {
+	Month string `json:"month"`
+	New   int    `json:"new"`
+	Total int    `json:"total"`
+}
+
+type SitemapManifest struct {
+	ID        int       `db:"id"`
+	GUID      string    `db:"guid"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+type SitemapProject struct {
+	GUID         string    `db:"guid"`
+	ManifestGUID string    `db:"manifest_guid"`
+	UpdatedAt    time.Time `db:"updated_at"`
+}
+
 //easyjson:json
 type ManifestExport struct {
 	ID           int             `db:"id" json:"id"`
@@ -96,6 +220,7 @@ type Project struct {
 	Entity    Entity          `json:"entity" db:"-"`
 
 	ID        int       `db:"id" json:"id"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 	Total     int       `db:"total" json:"-"`
 }
@@ -125,6 +250,7 @@ type Entity struct {
 	ManifestURL      v1.URL `json:"-" db:"-"`
 	WebpageURLStatus bool   `json:"-" db:"-"`
 
+	ID    int `db:"id" json:"-"`
 	Total int `db:"total" json:"-"`
 }
 
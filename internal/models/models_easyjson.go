@@ -310,6 +310,10 @@ func easyjsonD2b7633eDecodeGithubComFlossFundPortalInternalModels3(in *jlexer.Le
 			(out.Entity).UnmarshalEasyJSON(in)
 		case "id":
 			out.ID = int(in.Int())
+		case "created_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.CreatedAt).UnmarshalJSON(data))
+			}
 		case "updated_at":
 			if data := in.Raw(); in.Ok() {
 				in.AddError((out.UpdatedAt).UnmarshalJSON(data))
@@ -405,6 +409,11 @@ func easyjsonD2b7633eEncodeGithubComFlossFundPortalInternalModels3(out *jwriter.
 		out.RawString(prefix)
 		out.Int(int(in.ID))
 	}
+	{
+		const prefix string = ",\"created_at\":"
+		out.RawString(prefix)
+		out.Raw((in.CreatedAt).MarshalJSON())
+	}
 	{
 		const prefix string = ",\"updated_at\":"
 		out.RawString(prefix)
@@ -0,0 +1,251 @@
+// Package ogimg generates PNG social preview (Open Graph) cards for
+// entity and project pages and caches them on disk.
+package ogimg
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	Width  = 1200
+	Height = 630
+
+	padding  = 80
+	maxTags  = 5
+	maxLines = 2
+)
+
+var (
+	colBg      = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	colPrimary = color.RGBA{0x17, 0x9b, 0x4c, 0xff}
+	colText    = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	colGrey    = color.RGBA{0x77, 0x77, 0x77, 0xff}
+)
+
+// Card represents the contents of a social preview card.
+type Card struct {
+	Title    string
+	Subtitle string
+	Tags     []string
+	License  string
+	NumPlans int
+}
+
+// Gen renders and caches cards.
+type Gen struct {
+	dir string
+
+	regular *opentype.Font
+	bold    *opentype.Font
+}
+
+// New returns a new card generator that caches images in the given directory.
+func New(dir string) (*Gen, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating image cache directory: %v", err)
+	}
+
+	regular, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, err
+	}
+	bold, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Gen{dir: dir, regular: regular, bold: bold}, nil
+}
+
+// Get returns the PNG image for a card identified by key from the disk cache.
+// ver is the last modified date of the underlying data. If the cached version is
+// older, or doesn't exist, a new image is rendered and cached.
+func (g *Gen) Get(key string, ver time.Time, c Card) ([]byte, error) {
+	var (
+		prefix = fmt.Sprintf("%x", sha1.Sum([]byte(key)))
+		fPath  = filepath.Join(g.dir, fmt.Sprintf("%s-%d.png", prefix, ver.Unix()))
+	)
+
+	if b, err := os.ReadFile(fPath); err == nil {
+		return b, nil
+	}
+
+	b, err := g.Render(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove stale versions of the card.
+	if old, err := filepath.Glob(filepath.Join(g.dir, prefix+"-*.png")); err == nil {
+		for _, f := range old {
+			os.Remove(f)
+		}
+	}
+
+	// Write to a temp file and rename so that concurrent readers never see partial files.
+	tmp, err := os.CreateTemp(g.dir, prefix+"-*.tmp")
+	if err != nil {
+		return b, nil
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return b, nil
+	}
+	tmp.Close()
+	os.Rename(tmp.Name(), fPath)
+
+	return b, nil
+}
+
+// Render renders a card as a PNG image.
+func (g *Gen) Render(c Card) ([]byte, error) {
+	var (
+		img = image.NewRGBA(image.Rect(0, 0, Width, Height))
+		w   = Width - padding*2
+	)
+
+	draw.Draw(img, img.Bounds(), &image.Uniform{colBg}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, Width, 16), &image.Uniform{colPrimary}, image.Point{}, draw.Src)
+
+	fTitle, err := g.face(g.bold, 64)
+	if err != nil {
+		return nil, err
+	}
+	fSub, err := g.face(g.regular, 34)
+	if err != nil {
+		return nil, err
+	}
+	fMeta, err := g.face(g.regular, 28)
+	if err != nil {
+		return nil, err
+	}
+	fBrand, err := g.face(g.bold, 30)
+	if err != nil {
+		return nil, err
+	}
+
+	// Title (wrapped).
+	y := padding + 64
+	for _, l := range wrap(fTitle, c.Title, w, maxLines) {
+		drawText(img, fTitle, colText, padding, y, l)
+		y += 78
+	}
+
+	// Subtitle.
+	if c.Subtitle != "" {
+		y += 10
+		drawText(img, fSub, colGrey, padding, y, truncate(fSub, c.Subtitle, w))
+		y += 60
+	}
+
+	// Tags.
+	if len(c.Tags) > 0 {
+		tags := c.Tags
+		if len(tags) > maxTags {
+			tags = tags[:maxTags]
+		}
+		drawText(img, fMeta, colPrimary, padding, y+20, truncate(fMeta, "#"+strings.Join(tags, "  #"), w))
+	}
+
+	// Footer.
+	var meta []string
+	if c.License != "" {
+		meta = append(meta, strings.TrimPrefix(c.License, "spdx:"))
+	}
+	switch c.NumPlans {
+	case 0:
+	case 1:
+		meta = append(meta, "1 funding plan")
+	default:
+		meta = append(meta, fmt.Sprintf("%d funding plans", c.NumPlans))
+	}
+
+	fy := Height - padding
+	drawText(img, fMeta, colGrey, padding, fy, truncate(fMeta, strings.Join(meta, "  ·  "), w-300))
+
+	brand := "FLOSS/Fund"
+	drawText(img, fBrand, colPrimary, Width-padding-font.MeasureString(fBrand, brand).Round(), fy, brand)
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func (g *Gen) face(f *opentype.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+func drawText(img draw.Image, f font.Face, col color.Color, x, y int, s string) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(col),
+		Face: f,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+// wrap breaks a string into at most maxLines lines that fit in the given width.
+// The last line is truncated if the text overflows.
+func wrap(f font.Face, s string, width, maxLines int) []string {
+	var (
+		out  []string
+		line string
+	)
+
+	words := strings.Fields(s)
+	for n, w := range words {
+		next := strings.TrimSpace(line + " " + w)
+		if line == "" || font.MeasureString(f, next).Round() <= width {
+			line = next
+			continue
+		}
+
+		out = append(out, truncate(f, line, width))
+		if len(out) == maxLines-1 {
+			line = strings.Join(words[n:], " ")
+			break
+		}
+		line = w
+	}
+
+	if line != "" {
+		out = append(out, truncate(f, line, width))
+	}
+
+	return out
+}
+
+// truncate cuts a string to fit into the given width with an ellipsis.
+func truncate(f font.Face, s string, width int) string {
+	if font.MeasureString(f, s).Round() <= width {
+		return s
+	}
+
+	r := []rune(s)
+	for len(r) > 0 && font.MeasureString(f, string(r)+"…").Round() > width {
+		r = r[:len(r)-1]
+	}
+
+	return string(r) + "…"
+}
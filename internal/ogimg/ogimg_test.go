@@ -0,0 +1,61 @@
+package ogimg
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	g, err := New(t.TempDir())
+	assert.NoError(t, err)
+
+	b, err := g.Render(Card{
+		Title:    "A very long project name that will certainly not fit on a single line of the card",
+		Subtitle: "by Some Organisation",
+		Tags:     []string{"go", "web", "database", "cli", "devtools", "networking"},
+		License:  "spdx:MIT",
+		NumPlans: 3,
+	})
+	assert.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(b))
+	assert.NoError(t, err)
+	assert.Equal(t, Width, img.Bounds().Dx())
+	assert.Equal(t, Height, img.Bounds().Dy())
+}
+
+func TestGetCache(t *testing.T) {
+	dir := t.TempDir()
+	g, err := New(dir)
+	assert.NoError(t, err)
+
+	var (
+		v1 = time.Unix(1000, 0)
+		v2 = time.Unix(2000, 0)
+	)
+
+	b1, err := g.Get("@example.com", v1, Card{Title: "One"})
+	assert.NoError(t, err)
+
+	// Same version is served from the cache even if the card has changed.
+	b2, err := g.Get("@example.com", v1, Card{Title: "Two"})
+	assert.NoError(t, err)
+	assert.Equal(t, b1, b2)
+
+	// A newer version is re-rendered and replaces the old one.
+	b3, err := g.Get("@example.com", v2, Card{Title: "Two"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, b1, b3)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.png"))
+	assert.Len(t, files, 1)
+
+	_, err = os.Stat(files[0])
+	assert.NoError(t, err)
+}
@@ -19,10 +19,18 @@ WITH man AS (
         updated_at = NOW(),
         crawl_errors = 0,
         crawl_message = ''
-    RETURNING id
+    RETURNING id, language
+),
+lang AS (
+    -- Language of the manifest's text for search, declared or detected from the descriptions
+    -- out of the configured text search configurations ($7).
+    SELECT COALESCE(language, DETECT_LANGUAGE(
+        CONCAT_WS(' ', $1->'entity'->>'description', (SELECT STRING_AGG(p->>'description', ' ') FROM JSONB_ARRAY_ELEMENTS($1->'projects') AS p)),
+        $7::TEXT[]::REGCONFIG[]
+    )) AS lang FROM man
 ),
 entity AS (
-    INSERT INTO entities (type, role, name, email, phone, description, webpage_url, webpage_wellknown, manifest_id)
+    INSERT INTO entities (type, role, name, email, phone, description, webpage_url, webpage_wellknown, search_lang, manifest_id)
     SELECT
         ($1->'entity'->>'type')::entity_type,
         ($1->'entity'->>'role')::entity_role,
@@ -32,6 +40,7 @@ entity AS (
         $1->'entity'->>'description',
         $1->'entity'->'webpageUrl'->>'url',
         $1->'entity'->'webpageUrl'->>'wellKnown',
+        (SELECT lang FROM lang),
         (SELECT id FROM man)
     ON CONFLICT (manifest_id) DO UPDATE SET
         type = ($1->'entity'->>'type')::entity_type,
@@ -42,6 +51,7 @@ entity AS (
         description = $1->'entity'->>'description',
         webpage_url = $1->'entity'->'webpageUrl'->>'url',
         webpage_wellknown = $1->'entity'->'webpageUrl'->>'wellKnown',
+        search_lang = EXCLUDED.search_lang,
         updated_at = NOW()
     RETURNING id
 ),
@@ -53,7 +63,7 @@ delPrj AS (
 ),
 prj AS (
     INSERT INTO projects (
-        guid, name, description, webpage_url, webpage_wellknown, repository_url, repository_wellknown, licenses, tags, manifest_id
+        guid, name, description, webpage_url, webpage_wellknown, repository_url, repository_wellknown, licenses, tags, tags_canonical, search_lang, manifest_id
     )
     SELECT
         project->>'guid',
@@ -65,6 +75,8 @@ prj AS (
         project->'repositoryUrl'->>'wellKnown',
         ARRAY(SELECT JSONB_ARRAY_ELEMENTS_TEXT(project->'licenses')),
         ARRAY(SELECT JSONB_ARRAY_ELEMENTS_TEXT(project->'tags')),
+        CANONICAL_TAGS(ARRAY(SELECT JSONB_ARRAY_ELEMENTS_TEXT(project->'tags'))),
+        (SELECT lang FROM lang),
         (SELECT id FROM man) AS manifest_id
     FROM JSONB_ARRAY_ELEMENTS($1->'projects') AS project
     ON CONFLICT (manifest_id, guid) DO UPDATE
@@ -75,15 +87,153 @@ prj AS (
         repository_url = EXCLUDED.repository_url,
         repository_wellknown = EXCLUDED.repository_wellknown,
         licenses = EXCLUDED.licenses,
-        tags = EXCLUDED.tags
+        tags = EXCLUDED.tags,
+        tags_canonical = EXCLUDED.tags_canonical,
+        search_lang = EXCLUDED.search_lang,
+        -- Only bump the date if the project's contents have actually changed.
+        updated_at = (CASE WHEN
+            (projects.name, projects.description, projects.webpage_url, projects.webpage_wellknown,
+                projects.repository_url, projects.repository_wellknown, projects.licenses, projects.tags)
+            IS DISTINCT FROM
+            (EXCLUDED.name, EXCLUDED.description, EXCLUDED.webpage_url, EXCLUDED.webpage_wellknown,
+                EXCLUDED.repository_url, EXCLUDED.repository_wellknown, EXCLUDED.licenses, EXCLUDED.tags)
+            THEN NOW() ELSE projects.updated_at END)
+),
+delChannels AS (
+    -- Delete channels that have disappeared from the manifest.
+    DELETE FROM funding_channels WHERE manifest_id=(SELECT id FROM man) AND guid NOT IN (
+        SELECT c->>'guid' FROM JSONB_ARRAY_ELEMENTS(TO_JSONB_ARRAY($1->'funding'->'channels')) AS c
+    )
+),
+channels AS (
+    INSERT INTO funding_channels (manifest_id, guid, type, address, description, ord)
+    SELECT
+        (SELECT id FROM man),
+        c->>'guid',
+        (c->>'type')::channel_type,
+        COALESCE(c->>'address', ''),
+        COALESCE(c->>'description', ''),
+        ord
+    FROM JSONB_ARRAY_ELEMENTS(TO_JSONB_ARRAY($1->'funding'->'channels')) WITH ORDINALITY AS t(c, ord)
+    ON CONFLICT (manifest_id, guid) DO UPDATE
+    SET type = EXCLUDED.type,
+        address = EXCLUDED.address,
+        description = EXCLUDED.description,
+        ord = EXCLUDED.ord,
+        updated_at = NOW()
+    RETURNING id, guid
+),
+delPlans AS (
+    -- Delete plans that have disappeared from the manifest.
+    DELETE FROM funding_plans WHERE manifest_id=(SELECT id FROM man) AND guid NOT IN (
+        SELECT p->>'guid' FROM JSONB_ARRAY_ELEMENTS(TO_JSONB_ARRAY($1->'funding'->'plans')) AS p
+    )
+),
+plans AS (
+    INSERT INTO funding_plans (manifest_id, guid, status, name, description, amount, currency, frequency, ord)
+    SELECT
+        (SELECT id FROM man),
+        p->>'guid',
+        (p->>'status')::plan_status,
+        p->>'name',
+        COALESCE(p->>'description', ''),
+        COALESCE((p->>'amount')::NUMERIC, 0),
+        p->>'currency',
+        (p->>'frequency')::plan_frequency,
+        ord
+    FROM JSONB_ARRAY_ELEMENTS(TO_JSONB_ARRAY($1->'funding'->'plans')) WITH ORDINALITY AS t(p, ord)
+    ON CONFLICT (manifest_id, guid) DO UPDATE
+    SET status = EXCLUDED.status,
+        name = EXCLUDED.name,
+        description = EXCLUDED.description,
+        amount = EXCLUDED.amount,
+        currency = EXCLUDED.currency,
+        frequency = EXCLUDED.frequency,
+        ord = EXCLUDED.ord,
+        updated_at = NOW()
+    RETURNING id, guid
+),
+delPlanChannels AS (
+    -- Delete plan-channel relationships that have disappeared from the manifest.
+    DELETE FROM funding_plan_channels pc USING funding_plans p, funding_channels c
+    WHERE p.id = pc.plan_id AND c.id = pc.channel_id AND p.manifest_id = (SELECT id FROM man)
+    AND NOT EXISTS (
+        SELECT 1 FROM JSONB_ARRAY_ELEMENTS(TO_JSONB_ARRAY($1->'funding'->'plans')) AS np
+        WHERE np->>'guid' = p.guid AND TO_JSONB_ARRAY(np->'channels') @> TO_JSONB(c.guid)
+    )
+),
+planChannels AS (
+    INSERT INTO funding_plan_channels (plan_id, channel_id, ord)
+    SELECT DISTINCT ON (pl.id, ch.id) pl.id, ch.id, pc.ord
+    FROM JSONB_ARRAY_ELEMENTS(TO_JSONB_ARRAY($1->'funding'->'plans')) AS np
+    JOIN plans pl ON pl.guid = np->>'guid'
+    CROSS JOIN JSONB_ARRAY_ELEMENTS_TEXT(TO_JSONB_ARRAY(np->'channels')) WITH ORDINALITY AS pc(guid, ord)
+    JOIN channels ch ON ch.guid = pc.guid
+    ON CONFLICT (plan_id, channel_id) DO UPDATE SET ord = EXCLUDED.ord
+),
+delHistory AS (
+    DELETE FROM funding_history WHERE manifest_id=(SELECT id FROM man)
+),
+history AS (
+    INSERT INTO funding_history (manifest_id, year, income, expenses, taxes, currency, description, ord)
+    SELECT
+        (SELECT id FROM man),
+        (h->>'year')::INT,
+        COALESCE((h->>'income')::NUMERIC, 0),
+        COALESCE((h->>'expenses')::NUMERIC, 0),
+        COALESCE((h->>'taxes')::NUMERIC, 0),
+        h->>'currency',
+        COALESCE(h->>'description', ''),
+        ord
+    FROM JSONB_ARRAY_ELEMENTS(TO_JSONB_ARRAY($1->'funding'->'history')) WITH ORDINALITY AS t(h, ord)
 )
 SELECT (SELECT id FROM man) AS manifest_id;
 
 -- name: get-manifests
-SELECT id, guid, version, url, funding AS funding_raw, 
-       status, status_message, crawl_errors, 
-       crawl_message, created_at, updated_at, meta
-FROM manifests 
+SELECT id, guid, version, url,
+    -- Assemble the funding object from the normalised funding tables.
+    JSONB_BUILD_OBJECT(
+        'channels', COALESCE((
+            SELECT JSONB_AGG(JSONB_BUILD_OBJECT(
+                'guid', c.guid,
+                'type', c.type,
+                'address', c.address,
+                'description', c.description
+            ) ORDER BY c.ord)
+            FROM funding_channels c WHERE c.manifest_id = manifests.id
+        ), '[]'::JSONB),
+        'plans', COALESCE((
+            SELECT JSONB_AGG(JSONB_BUILD_OBJECT(
+                'guid', p.guid,
+                'status', p.status,
+                'name', p.name,
+                'description', p.description,
+                'amount', p.amount,
+                'currency', p.currency,
+                'frequency', p.frequency,
+                'channels', ARRAY(
+                    SELECT c.guid FROM funding_plan_channels pc
+                    JOIN funding_channels c ON c.id = pc.channel_id
+                    WHERE pc.plan_id = p.id ORDER BY pc.ord
+                )
+            ) ORDER BY p.ord)
+            FROM funding_plans p WHERE p.manifest_id = manifests.id
+        ), '[]'::JSONB),
+        'history', COALESCE((
+            SELECT JSONB_AGG(JSONB_BUILD_OBJECT(
+                'year', h.year,
+                'income', h.income,
+                'expenses', h.expenses,
+                'taxes', h.taxes,
+                'currency', h.currency,
+                'description', h.description
+            ) ORDER BY h.ord)
+            FROM funding_history h WHERE h.manifest_id = manifests.id
+        ), '[]'::JSONB)
+    ) AS funding_raw,
+    status, status_message, crawl_errors,
+    crawl_message, created_at, updated_at, meta
+FROM manifests
 WHERE 
 (CASE
     WHEN $1 > 0 THEN id = $1
@@ -102,6 +252,30 @@ LIMIT $4;
 -- name: get-manifest-status
 SELECT status FROM manifests WHERE url = $1;
 
+-- name: count-manifests
+SELECT COUNT(*) FROM manifests WHERE status = $1::manifest_status;
+
+-- name: count-manifests-by-status
+SELECT status::TEXT AS value, COUNT(*) AS count FROM manifests GROUP BY status ORDER BY status;
+
+-- name: get-sitemap-manifests
+-- $1 offset
+-- $2 limit
+SELECT m.id, m.guid, m.updated_at FROM manifests m WHERE m.status = 'active'
+ORDER BY m.id OFFSET $1 LIMIT $2;
+
+-- name: get-sitemap-projects
+-- Projects of active manifests in a range of project IDs.
+-- $1 from ID (exclusive)
+-- $2 to ID (inclusive)
+SELECT p.guid, m.guid AS manifest_guid, m.updated_at
+FROM projects p JOIN manifests m ON m.id = p.manifest_id AND m.status = 'active'
+WHERE p.id > $1 AND p.id <= $2
+ORDER BY p.id;
+
+-- name: get-max-project-id
+SELECT COALESCE(MAX(id), 0) FROM projects;
+
 -- name: get-for-crawling
 SELECT id, url, updated_at, status FROM manifests
     WHERE id > $1
@@ -114,6 +288,27 @@ SELECT id, url, updated_at, status FROM manifests
 -- name: update-manifest-status
 UPDATE manifests SET status=$2 WHERE id=$1;
 
+-- name: update-manifest-language
+-- Declares the text search configuration (language) of a manifest's text ('' = detect from $3)
+-- and updates the search index of its entity and projects.
+WITH man AS (
+    UPDATE manifests SET language = NULLIF($2, '')::REGCONFIG WHERE id = $1 RETURNING id, language
+),
+lang AS (
+    SELECT COALESCE(man.language, DETECT_LANGUAGE(
+        CONCAT_WS(' ', (SELECT description FROM entities WHERE manifest_id = man.id),
+            (SELECT STRING_AGG(description, ' ') FROM projects WHERE manifest_id = man.id)),
+        $3::TEXT[]::REGCONFIG[]
+    )) AS lang FROM man
+),
+entity AS (
+    UPDATE entities SET search_lang = (SELECT lang FROM lang) WHERE manifest_id = (SELECT id FROM man)
+),
+prj AS (
+    UPDATE projects SET search_lang = (SELECT lang FROM lang) WHERE manifest_id = (SELECT id FROM man)
+)
+SELECT lang FROM lang;
+
 -- name: update-manifest-date
 UPDATE manifests SET updated_at=NOW() WHERE id=$1;
 
@@ -133,7 +328,8 @@ DELETE FROM manifests WHERE
     CASE
         WHEN $1 > 0 THEN id = $1
         WHEN $2 != '' THEN guid = $2
-    END;
+    END
+RETURNING id;
 
 -- name: insert-report
 INSERT INTO reports (manifest_id, reason) 
@@ -153,6 +349,15 @@ WITH ranked_projects AS (
 )
 SELECT id, $1::INT AS total FROM ranked_projects WHERE rn <= 2 ORDER BY created_at DESC LIMIT $1
 
+-- name: get-feed-projects-snippet
+-- raw: true
+-- $1 tag (optional)
+-- $2 limit
+SELECT p.id, $2::INT AS total FROM projects p
+    JOIN manifests m ON m.id = p.manifest_id AND m.status = 'active'
+    WHERE ($1::TEXT = '' OR p.tags_canonical && TAGS_WITH_DESCENDANTS(CANONICAL_TAGS(ARRAY[$1::TEXT])))
+    ORDER BY p.%s DESC LIMIT $2
+
 -- name: query-projects-template
 -- raw: true
 WITH res AS (%query%),
@@ -186,57 +391,148 @@ SELECT
     WHERE m.status = 'active'
 ORDER BY o.ord;
 
--- name: search-projects-snippet
+-- name: search-projects-filter
 -- raw: true
--- $1 plaintext text search term
--- $2 tags[]
+-- WHERE conditions for project search that are shared by the search and facet queries.
+-- $1 plaintext text search term. Words match as prefixes, and project names also match fuzzily (typos).
+--    %search-langs% is replaced with the configured text search configurations (languages) on load.
+-- $2 tags[] (normalised, de-aliased, and expanded to include child tags in the taxonomy)
 -- $3 licenses[]
--- $4 offset
--- $5 limit
+-- $4 filter by plans? (bool)
+-- $5 min plan amount (0 = any)
+-- $6 max plan amount (0 = any)
+-- $7 plan currency ('' = any)
+-- $8 plan frequencies[]
+-- $9 plan status ('' = any)
+-- $10 channel types[]
+-- $11 currency to convert plan amounts to before comparing ('' = no conversion)
+-- $12 entity types[]
+-- $13 entity roles[]
+($1::TEXT = '' OR p.search_tokens @@ PREFIX_TSQUERY($1, %search-langs%) OR LOWER($1) <% LOWER(p.name)) AND
+(CARDINALITY($2::TEXT[]) = 0 OR p.tags_canonical && TAGS_WITH_DESCENDANTS(CANONICAL_TAGS($2))) AND
+(CARDINALITY($3::TEXT[]) = 0 OR p.licenses && $3) AND
+-- The manifest should have at least one plan that matches all the plan filters.
+($4::BOOLEAN = FALSE OR EXISTS (
+    SELECT 1 FROM funding_plans fp
+    WHERE fp.manifest_id = p.manifest_id
+        AND ($5::NUMERIC = 0 OR CONVERT_AMOUNT(fp.amount, fp.currency, $11::TEXT) >= $5)
+        AND ($6::NUMERIC = 0 OR CONVERT_AMOUNT(fp.amount, fp.currency, $11::TEXT) <= $6)
+        AND ($7::TEXT = '' OR fp.currency = $7)
+        AND (CARDINALITY($8::plan_frequency[]) = 0 OR fp.frequency = ANY($8::plan_frequency[]))
+        AND ($9::TEXT = '' OR fp.status::TEXT = $9)
+)) AND
+(CARDINALITY($10::TEXT[]) = 0 OR EXISTS (
+    SELECT 1 FROM funding_channels fc WHERE fc.manifest_id = p.manifest_id AND fc.type::TEXT = ANY($10)
+)) AND
+(CARDINALITY($12::TEXT[]) = 0 OR EXISTS (
+    SELECT 1 FROM entities e WHERE e.manifest_id = p.manifest_id AND e.type::TEXT = ANY($12)
+)) AND
+(CARDINALITY($13::TEXT[]) = 0 OR EXISTS (
+    SELECT 1 FROM entities e WHERE e.manifest_id = p.manifest_id AND e.role::TEXT = ANY($13)
+))
+
+-- name: search-projects-snippet
+-- raw: true
+-- $1-$13 search-projects-filter
+-- $14 offset
+-- $15 limit
+-- %order% is either rank or amount (the smallest active plan amount in the $11 currency).
 SELECT
     COUNT(*) OVER () AS total,
     id,
+    -- Full text rank blended with the similarity of the name to the query.
     CASE
-        WHEN $1::TEXT != '' THEN TS_RANK_CD(p.search_tokens, PLAINTO_TSQUERY('simple', $1))
+        WHEN $1::TEXT != '' THEN TS_RANK_CD(p.search_tokens, PREFIX_TSQUERY($1, %search-langs%)) + WORD_SIMILARITY(LOWER($1), LOWER(p.name))
         ELSE 0
-    END AS rank
+    END AS rank,
+    (
+        SELECT MIN(CONVERT_AMOUNT(fp.amount, fp.currency, $11::TEXT)) FROM funding_plans fp
+        WHERE fp.manifest_id = p.manifest_id AND fp.status = 'active'
+    ) AS amount
 FROM projects p
-WHERE
-    ($1::TEXT = '' OR p.search_tokens @@ PLAINTO_TSQUERY('simple', $1)) AND
-    (CARDINALITY($2::TEXT[]) = 0 OR p.tags && $2) AND
-    (CARDINALITY($3::TEXT[]) = 0 OR p.licenses && $3)
-ORDER BY
-    CASE
-        WHEN $1::TEXT != '' THEN TS_RANK_CD(p.search_tokens, PLAINTO_TSQUERY('simple', $1)) ELSE 0
-    END DESC
-    OFFSET $4 LIMIT $5
+WHERE %filter%
+ORDER BY %order%
+    OFFSET $14 LIMIT $15
 
--- name: get-projects-snippet
+-- name: get-project-facets
 -- raw: true
-SELECT COUNT(*) OVER () AS total, id FROM projects ORDER BY %s OFFSET $1 LIMIT $2
+-- Number of active projects for each value of a field (eg: tag, channel type).
+-- %field% is the field and %join% is the table or list that it's in.
+-- $1-$13 search-projects-filter
+-- $14 limit
+SELECT %field%::TEXT AS value, COUNT(DISTINCT p.id) AS count
+FROM projects p
+JOIN manifests m ON m.id = p.manifest_id
+%join%
+WHERE m.status = 'active' AND %filter%
+GROUP BY value ORDER BY count DESC, value LIMIT $14;
 
--- name: get-projects-by-manifest-snippet
+-- name: get-projects-page
 -- raw: true
-SELECT id, COUNT(*) OVER() AS total FROM projects WHERE manifest_id = $1 ORDER BY id
+-- IDs and sort keys of a page of projects of active manifests.
+-- %keyset% is the condition that selects the rows after a cursor ($2, $3) and %order% is the ORDER BY expression.
+-- $1 channel types[]
+-- $2 cursor sort key (text, NULL if the key is NULL)
+-- $3 cursor ID (0 = first page)
+-- $4 limit
+SELECT p.id, %key%::TEXT AS sort_key FROM projects p
+JOIN manifests m ON m.id = p.manifest_id AND m.status = 'active'
+JOIN entities e ON e.manifest_id = p.manifest_id
+WHERE (CARDINALITY($1::TEXT[]) = 0 OR EXISTS (
+    SELECT 1 FROM funding_channels fc WHERE fc.manifest_id = p.manifest_id AND fc.type::TEXT = ANY($1)
+)) AND ($3 = 0 OR %keyset%)
+ORDER BY %order% LIMIT $4;
+
+-- name: count-projects
+-- Number of projects of active manifests.
+-- $1 channel types[]
+SELECT COUNT(*) FROM projects p
+JOIN manifests m ON m.id = p.manifest_id AND m.status = 'active'
+WHERE CARDINALITY($1::TEXT[]) = 0 OR EXISTS (
+    SELECT 1 FROM funding_channels fc WHERE fc.manifest_id = p.manifest_id AND fc.type::TEXT = ANY($1)
+);
 
--- name: get-entities
+-- name: get-projects-by-ids-snippet
 -- raw: true
-SELECT
-    COUNT(*) OVER () AS total,
-    e.*,
-    (
-        SELECT COUNT(*)
-        FROM projects
-        WHERE manifest_id = e.manifest_id
-    ) AS num_projects,
-    m.guid AS manifest_guid,
-    m.url AS manifest_url
-FROM entities e JOIN manifests m ON m.id = e.manifest_id
-WHERE m.status = 'active'
-ORDER BY %s OFFSET $1 LIMIT $2;
+SELECT id, 0 AS total FROM UNNEST($1::INT[]) WITH ORDINALITY AS t(id, ord) ORDER BY ord
 
--- name: get-entity-by-manifest-snippet
+-- name: get-projects-by-manifests-snippet
 -- raw: true
+-- Projects of one or more manifests, grouped by manifest. total is the number of projects in the manifest.
+SELECT id, COUNT(*) OVER(PARTITION BY manifest_id) AS total FROM projects WHERE manifest_id = ANY($1::INT[]) ORDER BY manifest_id, id
+
+-- name: get-entities-page
+-- raw: true
+-- IDs and sort keys of a page of entities of active manifests.
+-- %keyset% is the condition that selects the rows after a cursor ($2, $3) and %order% is the ORDER BY expression.
+-- $1 channel types[]
+-- $2 cursor sort key (text, NULL if the key is NULL)
+-- $3 cursor ID (0 = first page)
+-- $4 limit
+SELECT e.id, %key%::TEXT AS sort_key FROM entities e
+JOIN manifests m ON m.id = e.manifest_id AND m.status = 'active'
+-- Stats are refreshed periodically and are missing for new manifests.
+LEFT JOIN entity_stats es ON es.manifest_id = e.manifest_id
+WHERE (CARDINALITY($1::TEXT[]) = 0 OR EXISTS (
+    SELECT 1 FROM funding_channels fc WHERE fc.manifest_id = e.manifest_id AND fc.type::TEXT = ANY($1)
+)) AND ($3 = 0 OR %keyset%)
+ORDER BY %order% LIMIT $4;
+
+-- name: count-entities
+-- Number of entities of active manifests.
+-- $1 channel types[]
+SELECT COUNT(*) FROM entities e
+JOIN manifests m ON m.id = e.manifest_id AND m.status = 'active'
+WHERE CARDINALITY($1::TEXT[]) = 0 OR EXISTS (
+    SELECT 1 FROM funding_channels fc WHERE fc.manifest_id = e.manifest_id AND fc.type::TEXT = ANY($1)
+);
+
+-- name: get-estimated-total
+-- Estimated number of active manifests or projects ($1) from the periodically refreshed directory stats.
+-- NULL if the stats haven't been refreshed yet.
+SELECT (SELECT value::INT FROM directory_stats WHERE stat = 'totals' AND key = $1);
+
+-- name: get-entities-by-manifests
 SELECT
     e.*,
     (
@@ -247,7 +543,7 @@ SELECT
     m.guid AS manifest_guid,
     m.url AS manifest_url
 FROM entities e JOIN manifests m ON m.id = e.manifest_id
-WHERE e.manifest_id = $1;
+WHERE e.manifest_id = ANY($1::INT[]);
 
 -- name: get-manifests-dump
 WITH project_json AS (
@@ -293,21 +589,271 @@ FROM manifests m
     LEFT JOIN project_json p ON p.manifest_id = m.id
 WHERE m.id > $1 ORDER BY m.id LIMIT $2;
 
+-- name: search-entities-filter
+-- raw: true
+-- WHERE conditions for entity search that are shared by the search, facet, and search-all queries.
+-- The params are named and are replaced with the positional params of the query that the filter is in,
+-- $1-$4 in the order below in the search and facet queries.
+-- %term% plaintext text search term. Words match as prefixes, and entity names also match fuzzily (typos).
+--    %search-langs% is replaced with the configured text search configurations (languages) on load.
+-- %channels% channel types[]
+-- %entity-types% entity types[]
+-- %entity-roles% entity roles[]
+(%term%::TEXT = '' OR e.search_tokens @@ PREFIX_TSQUERY(%term%, %search-langs%) OR LOWER(%term%) <% LOWER(e.name)) AND
+(CARDINALITY(%channels%::TEXT[]) = 0 OR EXISTS (
+    SELECT 1 FROM funding_channels fc WHERE fc.manifest_id = e.manifest_id AND fc.type::TEXT = ANY(%channels%)
+)) AND
+(CARDINALITY(%entity-types%::TEXT[]) = 0 OR e.type::TEXT = ANY(%entity-types%)) AND
+(CARDINALITY(%entity-roles%::TEXT[]) = 0 OR e.role::TEXT = ANY(%entity-roles%))
+
 -- name: search-entities
+-- raw: true
+-- Searches entities of active manifests. %order% is the ORDER BY expression.
+-- $1-$4 search-entities-filter
+-- $5 offset
+-- $6 limit
 SELECT
     COUNT(*) OVER () AS total,
-    t.*,
-    t.webpage_url AS "webpageUrl",
-    t.webpage_wellknown AS "webpageWellknown",
-    TS_RANK_CD(t.search_tokens, PLAINTO_TSQUERY('simple', $1)) AS rank,
+    e.*,
+    e.webpage_url AS "webpageUrl",
+    e.webpage_wellknown AS "webpageWellknown",
+    TS_RANK_CD(e.search_tokens, PREFIX_TSQUERY($1, %search-langs%)) + WORD_SIMILARITY(LOWER($1), LOWER(e.name)) AS rank,
     COALESCE(project_counts.num_projects, 0) AS num_projects,
     m.guid AS manifest_guid,
     m.url AS manifest_url
-FROM entities t
+FROM entities e
 LEFT JOIN (
     SELECT manifest_id, COUNT(*) AS num_projects
     FROM projects GROUP BY manifest_id
-) AS project_counts ON project_counts.manifest_id = t.manifest_id
-JOIN manifests m ON m.id = t.manifest_id
-WHERE t.search_tokens @@ PLAINTO_TSQUERY('simple', $1)
-ORDER BY rank DESC, t.id OFFSET $2 LIMIT $3;
+) AS project_counts ON project_counts.manifest_id = e.manifest_id
+JOIN manifests m ON m.id = e.manifest_id AND m.status = 'active'
+WHERE %filter%
+ORDER BY %order% OFFSET $5 LIMIT $6;
+
+-- name: search-all-snippet
+-- raw: true
+-- Searches projects and entities and returns their IDs merged by rank along with the number of each.
+-- %project-filter% is search-projects-filter and %entity-filter% is search-entities-filter
+-- with its params replaced with the matching ones of the project filter.
+-- $1-$13 search-projects-filter
+-- $14 include entities? (false if there are project only filters)
+-- $15 offset
+-- $16 limit
+WITH res AS (
+    SELECT 'project' AS type, p.id,
+        CASE
+            WHEN $1::TEXT != '' THEN TS_RANK_CD(p.search_tokens, PREFIX_TSQUERY($1, %search-langs%)) + WORD_SIMILARITY(LOWER($1), LOWER(p.name))
+            ELSE 0
+        END AS rank,
+        p.updated_at
+    FROM projects p JOIN manifests m ON m.id = p.manifest_id AND m.status = 'active'
+    WHERE %project-filter%
+    UNION ALL
+    SELECT 'entity', e.id,
+        CASE
+            WHEN $1::TEXT != '' THEN TS_RANK_CD(e.search_tokens, PREFIX_TSQUERY($1, %search-langs%)) + WORD_SIMILARITY(LOWER($1), LOWER(e.name))
+            ELSE 0
+        END,
+        e.updated_at
+    FROM entities e JOIN manifests m ON m.id = e.manifest_id AND m.status = 'active'
+    WHERE $14::BOOLEAN AND %entity-filter%
+),
+counts AS (
+    SELECT COUNT(*) FILTER (WHERE type = 'project') AS num_projects, COUNT(*) FILTER (WHERE type = 'entity') AS num_entities FROM res
+)
+-- There's always one row with the counts even if there are no results.
+SELECT counts.*, r.type, r.id FROM counts
+LEFT JOIN LATERAL (
+    SELECT type, id FROM res ORDER BY rank DESC, updated_at DESC NULLS LAST, type, id OFFSET $15 LIMIT $16
+) r ON TRUE;
+
+-- name: get-entities-by-ids
+SELECT
+    e.*,
+    e.webpage_url AS "webpageUrl",
+    e.webpage_wellknown AS "webpageWellknown",
+    (SELECT COUNT(*) FROM projects WHERE manifest_id = e.manifest_id) AS num_projects,
+    m.guid AS manifest_guid,
+    m.url AS manifest_url
+FROM entities e JOIN manifests m ON m.id = e.manifest_id
+WHERE e.id = ANY($1::INT[]);
+
+-- name: get-entity-facets
+-- raw: true
+-- Number of active entities for each value of a field (eg: type, channel type).
+-- %field% is the field and %join% is the table that it's in.
+-- $1-$4 search-entities-filter
+-- $5 limit
+SELECT %field%::TEXT AS value, COUNT(DISTINCT e.id) AS count
+FROM entities e
+JOIN manifests m ON m.id = e.manifest_id
+%join%
+WHERE m.status = 'active' AND %filter%
+GROUP BY value ORDER BY count DESC, value LIMIT $5;
+
+-- name: get-exchange-rates
+SELECT currency, rate FROM exchange_rates ORDER BY currency;
+
+-- name: replace-exchange-rates
+-- Replaces all exchange rates with the given ones as they should all be relative to the same base currency.
+-- $1 currencies[], $2 rates[]
+WITH del AS (
+    DELETE FROM exchange_rates WHERE currency != ALL($1::TEXT[])
+)
+INSERT INTO exchange_rates (currency, rate)
+    SELECT * FROM UNNEST($1::TEXT[], $2::NUMERIC[])
+ON CONFLICT (currency) DO UPDATE SET rate = EXCLUDED.rate, updated_at = NOW();
+
+-- name: get-directory-stats
+SELECT stat, key, currency, value FROM directory_stats ORDER BY stat, value DESC, key;
+
+-- name: refresh-view
+-- raw: true
+-- %s is the name of the materialized view. CONCURRENTLY requires a unique index on the view.
+REFRESH MATERIALIZED VIEW CONCURRENTLY %s;
+
+-- name: update-view-refreshed
+INSERT INTO settings (key, value) VALUES ('maintenance.views', JSONB_BUILD_OBJECT($1::TEXT, NOW()))
+    ON CONFLICT (key) DO UPDATE SET value = settings.value || EXCLUDED.value, updated_at = NOW();
+
+-- name: get-views-refreshed
+SELECT COALESCE((SELECT value FROM settings WHERE key = 'maintenance.views'), '{}'::JSONB);
+
+-- name: get-tag-aliases
+SELECT alias, tag, created_at FROM tag_aliases ORDER BY tag, alias;
+
+-- name: upsert-tag-alias
+-- Aliases are resolved one level deep. So, if the target is itself an alias, the alias
+-- points to its tag, and existing aliases that point to the new alias are re-pointed.
+-- No row is returned if the alias and the tag are the same.
+WITH t AS (
+    SELECT NORMALIZE_TAG($1) AS alias,
+        COALESCE((SELECT tag FROM tag_aliases WHERE alias = NORMALIZE_TAG($2)), NORMALIZE_TAG($2)) AS tag
+),
+upd AS (
+    UPDATE tag_aliases SET tag = (SELECT tag FROM t) WHERE tag = (SELECT alias FROM t)
+)
+INSERT INTO tag_aliases (alias, tag)
+    SELECT alias, tag FROM t WHERE alias != '' AND tag != '' AND alias != tag
+ON CONFLICT (alias) DO UPDATE SET tag = EXCLUDED.tag
+RETURNING alias;
+
+-- name: delete-tag-alias
+DELETE FROM tag_aliases WHERE alias = NORMALIZE_TAG($1);
+
+-- name: get-tag-taxonomy
+SELECT tag, COALESCE(parent, '') AS parent, created_at FROM tag_taxonomy ORDER BY COALESCE(parent, tag), tag;
+
+-- name: upsert-tag-taxonomy
+-- $1 tag, $2 parent tag ('' = top level)
+-- No row is returned if setting the parent would create a cycle.
+WITH RECURSIVE t AS (
+    SELECT NORMALIZE_TAG($1) AS tag, NULLIF(NORMALIZE_TAG($2), '') AS parent
+),
+-- The new parent and all its ancestors.
+anc AS (
+    SELECT parent AS tag FROM t WHERE parent IS NOT NULL
+    UNION
+    SELECT tt.parent FROM tag_taxonomy tt JOIN anc ON anc.tag = tt.tag WHERE tt.parent IS NOT NULL
+),
+ok AS (
+    SELECT * FROM t WHERE tag != '' AND tag NOT IN (SELECT tag FROM anc)
+),
+par AS (
+    -- Parents that don't exist are inserted as top level tags.
+    INSERT INTO tag_taxonomy (tag) SELECT parent FROM ok WHERE parent IS NOT NULL
+    ON CONFLICT (tag) DO NOTHING
+)
+INSERT INTO tag_taxonomy (tag, parent) SELECT tag, parent FROM ok
+ON CONFLICT (tag) DO UPDATE SET parent = EXCLUDED.parent
+RETURNING tag;
+
+-- name: delete-tag-taxonomy
+-- Children of the deleted tag become top level tags.
+WITH children AS (
+    UPDATE tag_taxonomy SET parent = NULL WHERE parent = NORMALIZE_TAG($1)
+)
+DELETE FROM tag_taxonomy WHERE tag = NORMALIZE_TAG($1);
+
+-- name: update-canonical-tags
+-- Recomputes canonical project tags after the aliases have changed.
+UPDATE projects SET tags_canonical = CANONICAL_TAGS(tags)
+    WHERE tags_canonical IS DISTINCT FROM CANONICAL_TAGS(tags);
+
+-- name: get-canonical-tag
+SELECT COALESCE((CANONICAL_TAGS(ARRAY[$1::TEXT]))[1], '');
+
+-- name: get-tag-counts
+-- Number of active projects per canonical tag.
+-- $1 offset, $2 limit
+SELECT COUNT(*) OVER () AS total, tag AS value, COUNT(*) AS count
+FROM projects p
+JOIN manifests m ON m.id = p.manifest_id AND m.status = 'active'
+CROSS JOIN UNNEST(p.tags_canonical) AS tag
+GROUP BY tag ORDER BY count DESC, tag OFFSET $1 LIMIT $2;
+
+-- name: get-license-counts
+-- Number of active projects per license.
+-- $1 offset, $2 limit
+SELECT COUNT(*) OVER () AS total, license AS value, COUNT(*) AS count
+FROM projects p
+JOIN manifests m ON m.id = p.manifest_id AND m.status = 'active'
+CROSS JOIN UNNEST(p.licenses) AS license
+GROUP BY license ORDER BY count DESC, license OFFSET $1 LIMIT $2;
+
+-- name: get-related-tags
+-- Tags that most often occur alongside the given tags or licenses in active projects.
+-- $1 tags[], $2 licenses[], $3 limit
+SELECT tag AS value, COUNT(*) AS count
+FROM projects p
+JOIN manifests m ON m.id = p.manifest_id AND m.status = 'active'
+CROSS JOIN UNNEST(p.tags_canonical) AS tag
+WHERE (CARDINALITY($1::TEXT[]) = 0 OR p.tags_canonical && TAGS_WITH_DESCENDANTS(CANONICAL_TAGS($1))) AND
+    (CARDINALITY($2::TEXT[]) = 0 OR p.licenses && $2) AND
+    tag != ALL(CANONICAL_TAGS($1))
+GROUP BY tag ORDER BY count DESC, tag LIMIT $3;
+
+-- name: get-search-suggestion
+-- "Did you mean" suggestion for a search query where the words that don't match any
+-- indexed word (even as a prefix) are replaced with the most similar indexed words.
+-- Returns an empty string if all the words match.
+WITH words AS (
+    SELECT w, ord FROM REGEXP_SPLIT_TO_TABLE(LOWER($1), '[^[:alnum:]]+') WITH ORDINALITY AS t(w, ord) WHERE w != ''
+),
+sugg AS (
+    SELECT w.ord, w.w, s.word FROM words w
+    LEFT JOIN LATERAL (
+        SELECT sw.word FROM search_words sw
+        WHERE sw.word % w.w AND NOT EXISTS (SELECT 1 FROM search_words x WHERE x.word LIKE w.w || '%')
+        ORDER BY SIMILARITY(sw.word, w.w) DESC, sw.ndoc DESC LIMIT 1
+    ) s ON TRUE
+)
+SELECT CASE WHEN BOOL_OR(word IS NOT NULL) THEN STRING_AGG(COALESCE(word, w), ' ' ORDER BY ord) ELSE '' END FROM sugg;
+
+-- name: autocomplete
+-- Project names, entity names, and popular tags that match a partial search query.
+-- Names that start with the query are ranked first, followed by names that contain it or are similar to it (typos).
+-- $1 lowercased query, $2 $1 escaped for LIKE, $3 limit per type
+(
+    SELECT 'project' AS type, CONCAT(m.guid, '/', p.guid) AS guid, p.name, 0 AS count
+    FROM projects p JOIN manifests m ON m.id = p.manifest_id AND m.status = 'active'
+    WHERE LOWER(p.name) LIKE '%' || $2 || '%' OR $1 <% LOWER(p.name)
+    ORDER BY STARTS_WITH(LOWER(p.name), $1) DESC, WORD_SIMILARITY($1, LOWER(p.name)) DESC, p.name
+    LIMIT $3
+)
+UNION ALL
+(
+    SELECT 'entity', m.guid, e.name, 0
+    FROM entities e JOIN manifests m ON m.id = e.manifest_id AND m.status = 'active'
+    WHERE LOWER(e.name) LIKE '%' || $2 || '%' OR $1 <% LOWER(e.name)
+    ORDER BY STARTS_WITH(LOWER(e.name), $1) DESC, WORD_SIMILARITY($1, LOWER(e.name)) DESC, e.name
+    LIMIT $3
+)
+UNION ALL
+(
+    -- Normalised tags only contain letters, numbers, and +#.- and need no LIKE escaping.
+    SELECT 'tag', tag, tag, tag_count FROM top_tags
+    WHERE NORMALIZE_TAG($1) != '' AND tag LIKE NORMALIZE_TAG($1) || '%'
+    ORDER BY tag_count DESC, tag
+    LIMIT $3
+);
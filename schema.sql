@@ -1,6 +1,35 @@
 CREATE EXTENSION IF NOT EXISTS pgcrypto;
 CREATE EXTENSION IF NOT EXISTS pg_trgm;
 
+-- Returns the given JSONB value if it's an array, or an empty array otherwise (eg: null).
+CREATE OR REPLACE FUNCTION TO_JSONB_ARRAY(v JSONB) RETURNS JSONB LANGUAGE SQL IMMUTABLE AS $$
+    SELECT CASE WHEN JSONB_TYPEOF(v) = 'array' THEN v ELSE '[]'::JSONB END
+$$;
+
+-- Converts a plain text search query into a tsquery that matches all the words as prefixes
+-- in any of the given text search configurations (stemmed, without stop words) or as is
+-- (simple), eg: "the libraries" in english => ('librari':*) | ('the':* & 'libraries':*)
+CREATE OR REPLACE FUNCTION PREFIX_TSQUERY(q TEXT, langs REGCONFIG[]) RETURNS TSQUERY LANGUAGE SQL IMMUTABLE AS $$
+    WITH words AS (
+        SELECT STRING_AGG(QUOTE_LITERAL(w) || ':*', ' & ') AS w
+        FROM REGEXP_SPLIT_TO_TABLE(LOWER(q), '[^[:alnum:]]+') AS w WHERE w != ''
+    )
+    SELECT COALESCE(STRING_AGG('(' || t.tq || ')', ' | ')::TSQUERY, ''::TSQUERY)
+    FROM words, (SELECT DISTINCT UNNEST(langs || 'simple'::REGCONFIG) AS cfg) l,
+    LATERAL (SELECT TO_TSQUERY(l.cfg, words.w)::TEXT AS tq) t
+    WHERE words.w IS NOT NULL AND t.tq != ''
+$$;
+
+-- Detects the language of a text out of the given text search configurations by the number of
+-- stop words of each in it. Returns the first configuration if there are none.
+CREATE OR REPLACE FUNCTION DETECT_LANGUAGE(t TEXT, langs REGCONFIG[]) RETURNS REGCONFIG LANGUAGE SQL STABLE AS $$
+    SELECT COALESCE((
+        SELECT u.l FROM UNNEST(langs) WITH ORDINALITY AS u(l, ord),
+        LATERAL (SELECT COUNT(*) AS n FROM TS_DEBUG(u.l, COALESCE(t, '')) WHERE lexemes = '{}') s
+        WHERE s.n > 0 ORDER BY s.n DESC, u.ord LIMIT 1
+    ), langs[1], 'simple'::REGCONFIG)
+$$;
+
 -- manifests
 DROP TYPE IF EXISTS manifest_status CASCADE; CREATE TYPE manifest_status AS ENUM ('pending', 'active', 'expiring', 'disabled', 'blocked');
 DROP TABLE IF EXISTS manifests CASCADE;
@@ -17,12 +46,13 @@ CREATE TABLE manifests (
     crawl_errors         INT NOT NULL DEFAULT 0,
     crawl_message        TEXT NULL,
 
+    -- Text search configuration (language) declared for the manifest. NULL = detect.
+    language             REGCONFIG NULL,
+
     created_at           TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
     updated_at           TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
 );
-DROP INDEX IF EXISTS idx_funding_channels; CREATE INDEX idx_funding_channels ON manifests USING GIN ((funding->'channels'));
-DROP INDEX IF EXISTS idx_funding_plans; CREATE INDEX idx_funding_plans ON manifests USING GIN ((funding->'plans'));
-DROP INDEX IF EXISTS idx_funding_history; CREATE INDEX idx_funding_history ON manifests USING GIN ((funding->'history'));
+DROP INDEX IF EXISTS idx_manifest_updated; CREATE INDEX idx_manifest_updated ON manifests(updated_at);
 
 -- -- entities
 DROP TYPE IF EXISTS entity_type CASCADE; CREATE TYPE entity_type AS ENUM ('individual', 'group', 'organisation', 'other');
@@ -41,6 +71,7 @@ CREATE TABLE IF NOT EXISTS entities (
     webpage_url         TEXT NOT NULL,
     webpage_wellknown   TEXT NULL,
     meta                JSONB NOT NULL DEFAULT '{}',
+    search_lang         REGCONFIG NOT NULL DEFAULT 'simple',
 
     created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
@@ -48,14 +79,59 @@ CREATE TABLE IF NOT EXISTS entities (
 DROP INDEX IF EXISTS idx_entity_manifest; CREATE INDEX idx_entity_manifest ON entities(manifest_id);
 DROP INDEX IF EXISTS idx_entity_name; CREATE INDEX idx_entity_name ON entities USING GIN (LOWER(name) gin_trgm_ops);
 DROP INDEX IF EXISTS idx_entity_email; CREATE INDEX idx_entity_email ON entities(LOWER(email));
+-- Keyset pagination of listings sorted by name in either direction (see get-entities-page).
+DROP INDEX IF EXISTS idx_entity_name_sort; CREATE INDEX idx_entity_name_sort ON entities(LOWER(name), id);
+DROP INDEX IF EXISTS idx_entity_name_sort_desc; CREATE INDEX idx_entity_name_sort_desc ON entities(LOWER(name) DESC NULLS LAST, id DESC);
 
 ALTER TABLE entities ADD COLUMN IF NOT EXISTS search_tokens TSVECTOR
 GENERATED ALWAYS AS (
-    SETWEIGHT(TO_TSVECTOR('simple', COALESCE(name, '')), 'A') ||
-    SETWEIGHT(TO_TSVECTOR('simple', COALESCE(description, '')), 'B')
+    SETWEIGHT(TO_TSVECTOR(search_lang, COALESCE(name, '')), 'A') ||
+    SETWEIGHT(TO_TSVECTOR(search_lang, COALESCE(description, '')), 'B')
 ) STORED;
 DROP INDEX IF EXISTS idx_entities_search; CREATE INDEX idx_entities_search ON entities USING GIN (search_tokens);
 
+-- tags
+-- Normalises a tag by case folding and replacing punctuation and whitespace with hyphens, eg: "Go Lang!" => "go-lang".
+CREATE OR REPLACE FUNCTION NORMALIZE_TAG(t TEXT) RETURNS TEXT LANGUAGE SQL IMMUTABLE AS $$
+    SELECT TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(TRIM(t)), '[^[:alnum:]+#.]+', '-', 'g'))
+$$;
+
+-- Admin managed aliases that map tag variants to canonical tags, eg: golang => go.
+DROP TABLE IF EXISTS tag_aliases CASCADE;
+CREATE TABLE tag_aliases (
+    alias               TEXT NOT NULL PRIMARY KEY CHECK (alias = NORMALIZE_TAG(alias)),
+    tag                 TEXT NOT NULL CHECK (tag = NORMALIZE_TAG(tag) AND tag != alias),
+    created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_tag_aliases_tag; CREATE INDEX idx_tag_aliases_tag ON tag_aliases(tag);
+
+-- Optional hierarchy of canonical tags, eg: web > frontend.
+DROP TABLE IF EXISTS tag_taxonomy CASCADE;
+CREATE TABLE tag_taxonomy (
+    tag                 TEXT NOT NULL PRIMARY KEY CHECK (tag = NORMALIZE_TAG(tag)),
+    parent              TEXT NULL CHECK (parent = NORMALIZE_TAG(parent) AND parent != tag),
+    created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_tag_taxonomy_parent; CREATE INDEX idx_tag_taxonomy_parent ON tag_taxonomy(parent);
+
+-- Returns the unique, normalised, and de-aliased tags in a list.
+CREATE OR REPLACE FUNCTION CANONICAL_TAGS(tags TEXT[]) RETURNS TEXT[] LANGUAGE SQL STABLE AS $$
+    SELECT COALESCE(ARRAY_AGG(DISTINCT COALESCE(a.tag, n.tag)), '{}')
+    FROM (SELECT NORMALIZE_TAG(t) AS tag FROM UNNEST(tags) AS t) n
+    LEFT JOIN tag_aliases a ON a.alias = n.tag
+    WHERE n.tag != ''
+$$;
+
+-- Returns the given tags along with all their descendants in the taxonomy.
+CREATE OR REPLACE FUNCTION TAGS_WITH_DESCENDANTS(tags TEXT[]) RETURNS TEXT[] LANGUAGE SQL STABLE AS $$
+    WITH RECURSIVE d AS (
+        SELECT UNNEST(tags) AS tag
+        UNION
+        SELECT t.tag FROM tag_taxonomy t JOIN d ON t.parent = d.tag
+    )
+    SELECT COALESCE(ARRAY_AGG(tag), '{}') FROM d
+$$;
+
 -- projects
 DROP TABLE IF EXISTS projects CASCADE;
 CREATE TABLE IF NOT EXISTS projects (
@@ -71,7 +147,9 @@ CREATE TABLE IF NOT EXISTS projects (
     repository_wellknown TEXT NULL,
     licenses             TEXT[] NOT NULL,
     tags                 TEXT[] NOT NULL,
+    tags_canonical       TEXT[] NOT NULL DEFAULT '{}',
     meta                 JSONB NOT NULL DEFAULT '{}',
+    search_lang          REGCONFIG NOT NULL DEFAULT 'simple',
 
     created_at           TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at           TIMESTAMP WITH TIME ZONE DEFAULT NOW()
@@ -81,14 +159,113 @@ DROP INDEX IF EXISTS idx_project_manifest; CREATE INDEX idx_project_manifest ON
 DROP INDEX IF EXISTS idx_project_name; CREATE INDEX idx_project_name ON projects USING GIN (LOWER(name) gin_trgm_ops);
 DROP INDEX IF EXISTS idx_project_licenses; CREATE INDEX idx_project_licenses ON projects USING GIN (licenses);
 DROP INDEX IF EXISTS idx_project_tags; CREATE INDEX idx_project_tags ON projects USING GIN (tags);
+DROP INDEX IF EXISTS idx_project_tags_canonical; CREATE INDEX idx_project_tags_canonical ON projects USING GIN (tags_canonical);
+-- Keyset pagination of listings sorted by name or license in either direction (see get-projects-page).
+DROP INDEX IF EXISTS idx_project_name_sort; CREATE INDEX idx_project_name_sort ON projects(name, id);
+DROP INDEX IF EXISTS idx_project_name_sort_desc; CREATE INDEX idx_project_name_sort_desc ON projects(name DESC NULLS LAST, id DESC);
+DROP INDEX IF EXISTS idx_project_license_sort; CREATE INDEX idx_project_license_sort ON projects((licenses[1]), id);
+DROP INDEX IF EXISTS idx_project_license_sort_desc; CREATE INDEX idx_project_license_sort_desc ON projects((licenses[1]) DESC NULLS LAST, id DESC);
 
 ALTER TABLE projects ADD COLUMN IF NOT EXISTS search_tokens TSVECTOR 
 GENERATED ALWAYS AS (
-    SETWEIGHT(TO_TSVECTOR('simple', COALESCE(name, '')), 'A') ||
-    SETWEIGHT(TO_TSVECTOR('simple', COALESCE(description, '')), 'B')
+    SETWEIGHT(TO_TSVECTOR(search_lang, COALESCE(name, '')), 'A') ||
+    SETWEIGHT(TO_TSVECTOR(search_lang, COALESCE(description, '')), 'B')
 ) STORED;
 DROP INDEX IF EXISTS idx_projects_search; CREATE INDEX idx_projects_search ON projects USING GIN (search_tokens);
 
+-- funding channels
+DROP TYPE IF EXISTS channel_type CASCADE; CREATE TYPE channel_type AS ENUM ('bank', 'payment-provider', 'cheque', 'cash', 'other');
+DROP TABLE IF EXISTS funding_channels CASCADE;
+CREATE TABLE funding_channels (
+    id                  SERIAL PRIMARY KEY,
+    manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+
+    guid                TEXT NOT NULL,
+    type                channel_type NOT NULL,
+    address             TEXT NOT NULL DEFAULT '',
+    description         TEXT NOT NULL DEFAULT '',
+
+    -- Position of the item in the manifest.
+    ord                 INT NOT NULL DEFAULT 0,
+
+    created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_channel_guid; CREATE UNIQUE INDEX idx_channel_guid ON funding_channels(manifest_id, guid);
+DROP INDEX IF EXISTS idx_channel_type; CREATE INDEX idx_channel_type ON funding_channels(type);
+
+-- funding plans
+DROP TYPE IF EXISTS plan_status CASCADE; CREATE TYPE plan_status AS ENUM ('active', 'inactive');
+DROP TYPE IF EXISTS plan_frequency CASCADE; CREATE TYPE plan_frequency AS ENUM ('one-time', 'weekly', 'fortnightly', 'monthly', 'yearly', 'other');
+DROP TABLE IF EXISTS funding_plans CASCADE;
+CREATE TABLE funding_plans (
+    id                  SERIAL PRIMARY KEY,
+    manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+
+    guid                TEXT NOT NULL,
+    status              plan_status NOT NULL,
+    name                TEXT NOT NULL,
+    description         TEXT NOT NULL DEFAULT '',
+    amount              NUMERIC NOT NULL DEFAULT 0,
+    currency            TEXT NOT NULL,
+    frequency           plan_frequency NOT NULL,
+    ord                 INT NOT NULL DEFAULT 0,
+
+    created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_plan_guid; CREATE UNIQUE INDEX idx_plan_guid ON funding_plans(manifest_id, guid);
+DROP INDEX IF EXISTS idx_plan_amount; CREATE INDEX idx_plan_amount ON funding_plans(currency, amount);
+DROP INDEX IF EXISTS idx_plan_frequency; CREATE INDEX idx_plan_frequency ON funding_plans(frequency);
+
+-- plans <> channels
+DROP TABLE IF EXISTS funding_plan_channels CASCADE;
+CREATE TABLE funding_plan_channels (
+    plan_id             INTEGER NOT NULL REFERENCES funding_plans(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    channel_id          INTEGER NOT NULL REFERENCES funding_channels(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    ord                 INT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (plan_id, channel_id)
+);
+DROP INDEX IF EXISTS idx_plan_channels_channel; CREATE INDEX idx_plan_channels_channel ON funding_plan_channels(channel_id);
+
+-- funding history
+DROP TABLE IF EXISTS funding_history CASCADE;
+CREATE TABLE funding_history (
+    id                  SERIAL PRIMARY KEY,
+    manifest_id         INTEGER NOT NULL REFERENCES manifests(id) ON DELETE CASCADE ON UPDATE CASCADE,
+
+    year                INT NOT NULL,
+    income              NUMERIC NOT NULL DEFAULT 0,
+    expenses            NUMERIC NOT NULL DEFAULT 0,
+    taxes               NUMERIC NOT NULL DEFAULT 0,
+    currency            TEXT NOT NULL,
+    description         TEXT NOT NULL DEFAULT '',
+    ord                 INT NOT NULL DEFAULT 0,
+
+    created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_history_manifest; CREATE INDEX idx_history_manifest ON funding_history(manifest_id, year);
+
+-- exchange rates
+-- Rates are the units of a currency per one unit of a common base currency (eg: USD = 1).
+-- They're imported from a local file with --import-rates.
+DROP TABLE IF EXISTS exchange_rates CASCADE;
+CREATE TABLE exchange_rates (
+    currency            TEXT NOT NULL PRIMARY KEY,
+    rate                NUMERIC NOT NULL CHECK (rate > 0),
+    updated_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Converts an amount from one currency to another. Returns the amount as-is if
+-- dst is empty or the same as src, and NULL if there's no rate for either currency.
+CREATE OR REPLACE FUNCTION CONVERT_AMOUNT(amount NUMERIC, src TEXT, dst TEXT) RETURNS NUMERIC LANGUAGE SQL STABLE AS $$
+    SELECT CASE
+        WHEN COALESCE(dst, '') = '' OR src = dst THEN amount
+        ELSE amount / (SELECT rate FROM exchange_rates WHERE currency = src) * (SELECT rate FROM exchange_rates WHERE currency = dst)
+    END
+$$;
+
 -- settings
 DROP TABLE IF EXISTS settings CASCADE;
 CREATE TABLE settings (
@@ -101,7 +278,92 @@ DROP INDEX IF EXISTS idx_settings_key; CREATE INDEX idx_settings_key ON settings
 -- top tags.
 DROP MATERIALIZED VIEW IF EXISTS top_tags;
 CREATE MATERIALIZED VIEW top_tags AS
-SELECT unnest(tags) AS tag, COUNT(*) AS tag_count FROM projects GROUP BY unnest(tags) ORDER BY tag_count DESC LIMIT 1000;
+SELECT unnest(tags_canonical) AS tag, COUNT(*) AS tag_count FROM projects GROUP BY unnest(tags_canonical) ORDER BY tag_count DESC LIMIT 1000;
+CREATE UNIQUE INDEX idx_top_tags ON top_tags(tag);
+
+-- directory stats.
+-- Aggregate statistics of the whole directory as (stat, key, currency, value) rows.
+DROP MATERIALIZED VIEW IF EXISTS directory_stats;
+CREATE MATERIALIZED VIEW directory_stats AS
+WITH act AS (SELECT id, created_at FROM manifests WHERE status = 'active')
+-- Totals.
+SELECT 'totals' AS stat, 'manifests' AS key, '' AS currency, COUNT(*)::NUMERIC AS value FROM act
+UNION ALL SELECT 'totals', 'projects', '', COUNT(*) FROM projects p JOIN act ON act.id = p.manifest_id
+UNION ALL SELECT 'totals', 'plans', '', COUNT(*) FROM funding_plans fp JOIN act ON act.id = fp.manifest_id WHERE fp.status = 'active'
+UNION ALL SELECT 'totals', 'channels', '', COUNT(*) FROM funding_channels fc JOIN act ON act.id = fc.manifest_id
+-- Manifests by status (all manifests).
+UNION ALL SELECT 'manifests_status', status::TEXT, '', COUNT(*) FROM manifests GROUP BY status
+-- Entities by type and role.
+UNION ALL SELECT 'entities_type', e.type::TEXT, '', COUNT(*) FROM entities e JOIN act ON act.id = e.manifest_id GROUP BY e.type
+UNION ALL SELECT 'entities_role', e.role::TEXT, '', COUNT(*) FROM entities e JOIN act ON act.id = e.manifest_id GROUP BY e.role
+-- Top projects by license and tag.
+UNION ALL SELECT 'projects_license', key, '', num FROM (
+    SELECT UNNEST(p.licenses) AS key, COUNT(*) AS num FROM projects p JOIN act ON act.id = p.manifest_id
+    GROUP BY key ORDER BY num DESC LIMIT 50
+) l
+UNION ALL SELECT 'projects_tag', key, '', num FROM (
+    SELECT UNNEST(p.tags_canonical) AS key, COUNT(*) AS num FROM projects p JOIN act ON act.id = p.manifest_id
+    GROUP BY key ORDER BY num DESC LIMIT 50
+) t
+-- Active plans by frequency.
+UNION ALL SELECT 'plans_frequency', fp.frequency::TEXT, '', COUNT(*)
+    FROM funding_plans fp JOIN act ON act.id = fp.manifest_id WHERE fp.status = 'active' GROUP BY fp.frequency
+-- Sum of requested funding per month (recurring active plans) in each currency.
+UNION ALL SELECT 'plans_monthly', '', fp.currency, SUM(
+    CASE fp.frequency
+        WHEN 'weekly' THEN fp.amount * 52 / 12
+        WHEN 'fortnightly' THEN fp.amount * 26 / 12
+        WHEN 'monthly' THEN fp.amount
+        WHEN 'yearly' THEN fp.amount / 12
+    END)
+    FROM funding_plans fp JOIN act ON act.id = fp.manifest_id
+    WHERE fp.status = 'active' AND fp.frequency IN ('weekly', 'fortnightly', 'monthly', 'yearly')
+    GROUP BY fp.currency
+-- Yearly income and expenses from the financial history in each currency.
+UNION ALL SELECT 'history_income', h.year::TEXT, h.currency, SUM(h.income)
+    FROM funding_history h JOIN act ON act.id = h.manifest_id GROUP BY h.year, h.currency
+UNION ALL SELECT 'history_expenses', h.year::TEXT, h.currency, SUM(h.expenses)
+    FROM funding_history h JOIN act ON act.id = h.manifest_id GROUP BY h.year, h.currency
+-- New listings per month.
+UNION ALL SELECT 'listings_month', TO_CHAR(DATE_TRUNC('month', created_at), 'YYYY-MM'), '', COUNT(*)
+    FROM act GROUP BY 2;
+CREATE UNIQUE INDEX idx_directory_stats ON directory_stats(stat, key, currency);
+
+-- entity stats.
+-- Number of projects and active funding plans, and the requested funding per month of each manifest
+-- for sorting entity listings. Funding is in the base currency of the exchange rates (rate = 1) and
+-- plans in currencies without rates are skipped.
+DROP MATERIALIZED VIEW IF EXISTS entity_stats;
+CREATE MATERIALIZED VIEW entity_stats AS
+SELECT m.id AS manifest_id,
+    (SELECT COUNT(*) FROM projects p WHERE p.manifest_id = m.id) AS num_projects,
+    (SELECT COUNT(*) FROM funding_plans fp WHERE fp.manifest_id = m.id AND fp.status = 'active') AS num_plans,
+    COALESCE((
+        SELECT SUM(CONVERT_AMOUNT(
+            CASE fp.frequency
+                WHEN 'weekly' THEN fp.amount * 52 / 12
+                WHEN 'fortnightly' THEN fp.amount * 26 / 12
+                WHEN 'monthly' THEN fp.amount
+                WHEN 'yearly' THEN fp.amount / 12
+            END, fp.currency, base.currency))
+        FROM funding_plans fp
+        WHERE fp.manifest_id = m.id AND fp.status = 'active' AND fp.frequency IN ('weekly', 'fortnightly', 'monthly', 'yearly')
+            AND base.currency IS NOT NULL
+    ), 0) AS monthly_funding
+FROM manifests m
+LEFT JOIN (SELECT currency FROM exchange_rates WHERE rate = 1 ORDER BY currency LIMIT 1) base ON TRUE;
+CREATE UNIQUE INDEX idx_entity_stats ON entity_stats(manifest_id);
+
+-- search words.
+-- Unique words (unstemmed) in the names and descriptions of projects and entities for "did you mean" suggestions.
+DROP MATERIALIZED VIEW IF EXISTS search_words;
+CREATE MATERIALIZED VIEW search_words AS
+    SELECT word, ndoc FROM TS_STAT($$
+        SELECT TO_TSVECTOR('simple', CONCAT_WS(' ', name, description)) FROM projects
+        UNION ALL SELECT TO_TSVECTOR('simple', CONCAT_WS(' ', name, description)) FROM entities
+    $$);
+CREATE UNIQUE INDEX idx_search_words ON search_words(word);
+CREATE INDEX idx_search_words_trgm ON search_words USING GIN (word gin_trgm_ops);
 
 -- reports
 DROP TABLE IF EXISTS reports CASCADE;
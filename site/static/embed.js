@@ -0,0 +1,38 @@
+// Embeds funding widgets on third party pages. Usage:
+//
+// <div data-funding-widget="@github.com/user" data-theme="dark" data-project="project-guid"></div>
+// <script src="https://dir.floss.fund/static/embed.js" async></script>
+(() => {
+    const root = new URL(document.currentScript.src).origin;
+
+    document.querySelectorAll("[data-funding-widget]").forEach(el => {
+        const u = new URL(`${root}/embed/${el.dataset.fundingWidget}`);
+        ["theme", "project"].forEach(p => {
+            if (el.dataset[p]) {
+                u.searchParams.set(p, el.dataset[p]);
+            }
+        });
+
+        const frame = document.createElement("iframe");
+        frame.src = u.toString();
+        frame.title = "Funding";
+        frame.loading = "lazy";
+        Object.assign(frame.style, { width: "100%", border: "0", height: "300px" });
+
+        el.innerHTML = "";
+        el.appendChild(frame);
+    });
+
+    // Resize iframes to fit their content.
+    window.addEventListener("message", (ev) => {
+        if (ev.origin !== root || !ev.data || !ev.data.fundingWidget) {
+            return;
+        }
+
+        document.querySelectorAll("[data-funding-widget] iframe").forEach(f => {
+            if (f.contentWindow === ev.source) {
+                f.style.height = `${ev.data.height}px`;
+            }
+        });
+    });
+})();
@@ -1,31 +1,119 @@
-import { autocomp } from "./lib.js";
-
-let TAGS = []
+// Search box autocomplete. Project, entity, and tag suggestions are fetched with htmx
+// and tags from /api/tags are matched locally. Up/down arrows move through the suggestions,
+// Tab completes the selected one in the search box, Enter opens it, and escape or
+// clicking outside hides them.
+let TAGS = [];
 if (!localStorage.tags) {
     fetch("/api/tags")
     .then(response => response.json())
     .then(data => {
-        TAGS = data.data;
+        TAGS = data.data || [];
         localStorage.tags = TAGS.join("|");
     });
 } else {
     TAGS = localStorage.tags.split("|");
 }
 
-const qInput = document.querySelector("input[data-autocomp-tags]");
-const isTags = document.querySelector(".search input[name=field][value=tags]");
-if (qInput) {
-    autocomp(qInput, {
-        onQuery: async (val) => {
-            if (!isTags.checked) {
-                return [];
-            }
-            const q = val.trim().toLowerCase();
-            return TAGS.filter(s => s.includes(q)).slice(0, 10);
-        },
-
-        onSelect: (val) => {
-            return val;
+const acList = document.querySelector("#autocomp");
+if (acList) {
+    const qInput = document.querySelector("form.search input[name=q]");
+    let sel = -1;
+
+    const items = () => acList.querySelectorAll(".autocomp-item");
+
+    const highlight = (n) => {
+        sel = n;
+        items().forEach((el, i) => {
+            el.classList.toggle("autocomp-sel", i === sel);
+            el.setAttribute("aria-selected", i === sel);
+        });
+
+        if (sel >= 0) {
+            qInput.setAttribute("aria-activedescendant", `autocomp-${sel}`);
+        } else {
+            qInput.removeAttribute("aria-activedescendant");
+        }
+    };
+
+    const close = () => {
+        acList.innerHTML = "";
+        qInput.setAttribute("aria-expanded", false);
+        highlight(-1);
+    };
+
+    // Add local tag matches that aren't already in the suggestions.
+    const addTags = () => {
+        const q = qInput.value.trim().toLowerCase();
+        if (q.length < 2) {
+            return;
+        }
+
+        const have = new Set([...acList.querySelectorAll(".autocomp-item[data-tag]")].map(el => el.dataset.value));
+        TAGS.filter(t => t.includes(q) && !have.has(t)).slice(0, 5).forEach(t => {
+            const a = document.createElement("a");
+            a.href = `/tags/${encodeURIComponent(t)}`;
+            a.className = "autocomp-item";
+            a.setAttribute("role", "option");
+            a.dataset.tag = "";
+            a.dataset.value = t;
+            a.innerHTML = `<span class="text-small text-grey">Tag</span> `;
+            a.append(t);
+            acList.appendChild(a);
+        });
+    };
+
+    document.addEventListener("htmx:afterSwap", (e) => {
+        if (e.detail.target !== acList) {
+            return;
+        }
+
+        addTags();
+        items().forEach((el, i) => el.id = `autocomp-${i}`);
+        qInput.setAttribute("aria-expanded", items().length > 0);
+        highlight(-1);
+    });
+
+    qInput.addEventListener("keydown", (e) => {
+        const all = items();
+
+        switch (e.key) {
+            case "ArrowDown":
+            case "ArrowUp":
+                if (all.length === 0) {
+                    return;
+                }
+                e.preventDefault();
+
+                const d = e.key === "ArrowDown" ? 1 : -1;
+                highlight(sel < 0 ? (d > 0 ? 0 : all.length - 1) : (sel + d + all.length) % all.length);
+                break;
+
+            case "Tab":
+                if (sel < 0) {
+                    return;
+                }
+                e.preventDefault();
+                qInput.value = all[sel].dataset.value;
+                close();
+                break;
+
+            case "Enter":
+                if (sel < 0) {
+                    return;
+                }
+                e.preventDefault();
+                all[sel].click();
+                break;
+
+            case "Escape":
+                close();
+                break;
+        }
+    });
+
+    document.addEventListener("click", (e) => {
+        if (!acList.contains(e.target)) {
+            close();
         }
     });
 }
@@ -45,8 +133,9 @@ document.addEventListener("keydown", function(event) {
 
     // Set initial values
     ["order_by", "order"].forEach(param => {
-      if (params.has(param)) {
-        document.querySelector(`select[name="${param}"] option.${params.get(param)}`).selected = true;
+      const o = params.has(param) && document.querySelector(`select[name="${param}"] option.${CSS.escape(params.get(param))}`);
+      if (o) {
+        o.selected = true;
       }
     });
 
@@ -54,6 +143,8 @@ document.addEventListener("keydown", function(event) {
     document.querySelectorAll(".order select").forEach(e =>
       e.onchange = () => {
         params.set("page", 1);
+        params.delete("after");
+        params.delete("before");
         params.set(e.name, e.options[e.selectedIndex].className);
         location.search = params.toString();
       }